@@ -0,0 +1,173 @@
+package client
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+)
+
+// assertion is a check of a metric of every step of a test, e.g. "p99_latency_ms < 20"
+type assertion struct {
+	expression string
+	metric     string
+	operator   string
+	threshold  float64
+}
+
+var assertionPattern = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(<=|>=|==|!=|<|>)\s*(\S+)\s*$`)
+
+func parseAssertion(expression string) (assertion, error) {
+	match := assertionPattern.FindStringSubmatch(expression)
+	if match == nil {
+		return assertion{}, fmt.Errorf("assertion %q is not of the form <metric> <operator> <number>", expression)
+	}
+	threshold, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return assertion{}, fmt.Errorf("assertion %q: %q is not a number", expression, match[3])
+	}
+	return assertion{strings.TrimSpace(expression), match[1], match[2], threshold}, nil
+}
+
+// parseAssertions parses the assertions of each test of the configuration, which are keyed by the name of the test in the
+// configuration, e.g. "https_burst". A comparison run is not checked against assertions, so it cannot have any
+func parseAssertions(config *types.Configuration) (map[string][]assertion, error) {
+	if config.Client.Comparison.Enable && len(config.Client.Assertions) > 0 {
+		return nil, fmt.Errorf("assertions are not checked in comparison mode")
+	}
+	testNames := map[string]bool{}
+	tests := reflect.TypeOf(config.Client.Tests)
+	for i := 0; i < tests.NumField(); i++ {
+		testNames[tests.Field(i).Tag.Get("yaml")] = true
+	}
+	assertions := map[string][]assertion{}
+	for test, expressions := range config.Client.Assertions {
+		if !testNames[test] {
+			return nil, fmt.Errorf("assertions of unknown test %q", test)
+		}
+		for _, expression := range expressions {
+			a, err := parseAssertion(expression)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", test, err)
+			}
+			assertions[test] = append(assertions[test], a)
+		}
+	}
+	return assertions, nil
+}
+
+func (e assertion) holds(value float64) bool {
+	switch e.operator {
+	case "<":
+		return value < e.threshold
+	case "<=":
+		return value <= e.threshold
+	case ">":
+		return value > e.threshold
+	case ">=":
+		return value >= e.threshold
+	case "==":
+		return value == e.threshold
+	case "!=":
+		return value != e.threshold
+	}
+	return false
+}
+
+// evaluateAssertions checks the assertions of the running test against every step it recorded and adds its test suite to
+// the JUnit report. An assertion fails if any step breaks it or if no step reported its metric
+func (e *resultRecorder) evaluateAssertions() junitTestSuite {
+	elapsed := fmt.Sprintf("%.3f", time.Since(e.started).Seconds())
+	suite := junitTestSuite{Name: e.test, Timestamp: e.started.UTC().Format(time.RFC3339), Time: elapsed}
+	for _, a := range e.assertions[e.test] {
+		countChecked := 0
+		var failures []string
+		for _, step := range e.steps {
+			value, ok := step.Metrics[a.metric]
+			if !ok {
+				continue
+			}
+			countChecked++
+			if !a.holds(value) {
+				failures = append(failures, fmt.Sprintf("step %s: %s = %.3f", step.Step, a.metric, value))
+			}
+		}
+		testCase := junitTestCase{Classname: e.test, Name: a.expression, Time: elapsed}
+		message := fmt.Sprintf("%s failed in %d of %d checks", a.expression, len(failures), countChecked)
+		if countChecked == 0 {
+			message = fmt.Sprintf("%s failed because no step reported %s", a.expression, a.metric)
+			failures = append(failures, message)
+		}
+		if len(failures) > 0 {
+			testCase.Failure = &junitFailure{Message: message, Text: strings.Join(failures, "\n")}
+			suite.Failures++
+			slog.Error("Assertion failed: "+e.test+" "+message, "failures", strings.Join(failures, "; "))
+		} else {
+			slog.Info("Assertion passed: "+e.test+" "+a.expression, "checks", countChecked)
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	if len(suite.TestCases) == 0 {
+		// a test without assertions passes if it ran
+		suite.TestCases = append(suite.TestCases, junitTestCase{Classname: e.test, Name: "run", Time: elapsed})
+	}
+	suite.Tests = len(suite.TestCases)
+	e.suites = append(e.suites, suite)
+	e.countFailures += suite.Failures
+	return suite
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"` // seconds
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"` // seconds
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes a JUnit XML report with a test suite per test and a test case per assertion to <name>.xml
+func (e *resultRecorder) writeJUnitReport(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	report := junitTestSuites{Name: "network-performance-tester", Failures: e.countFailures, Suites: e.suites}
+	for _, suite := range e.suites {
+		report.Tests += suite.Tests
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		slog.Error("Could not encode the JUnit report", "error", err)
+		return
+	}
+	filename := testResultsDirectory + name + ".xml"
+	if err := os.WriteFile(filename, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		slog.Error("Could not write the JUnit report", "file", filename, "error", err)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+)
+
+func TestParseAssertion(t *testing.T) {
+	for _, c := range []struct {
+		expression string
+		want       assertion
+	}{
+		{"p99_latency_ms < 20", assertion{"p99_latency_ms < 20", "p99_latency_ms", "<", 20}},
+		{"p99_latency_ms <= 20", assertion{"p99_latency_ms <= 20", "p99_latency_ms", "<=", 20}},
+		{"throughput_mbps > 100", assertion{"throughput_mbps > 100", "throughput_mbps", ">", 100}},
+		{"throughput_mbps>=100", assertion{"throughput_mbps>=100", "throughput_mbps", ">=", 100}},
+		{" failure_rate == 0 ", assertion{"failure_rate == 0", "failure_rate", "==", 0}},
+		{"loss_rate != 1e-3", assertion{"loss_rate != 1e-3", "loss_rate", "!=", 0.001}},
+	} {
+		got, err := parseAssertion(c.expression)
+		if err != nil {
+			t.Errorf("parseAssertion(%q) failed: %v", c.expression, err)
+		} else if got != c.want {
+			t.Errorf("parseAssertion(%q) = %+v, want %+v", c.expression, got, c.want)
+		}
+	}
+
+	for _, expression := range []string{
+		"",
+		"p99_latency_ms",
+		"p99_latency_ms < ",
+		"p99_latency_ms =< 20",
+		"p99_latency_ms = 20",
+		"P99_latency_ms < 20",
+		"p99 latency < 20",
+		"p99_latency_ms < 20 ms",
+		"p99_latency_ms < fast",
+	} {
+		if got, err := parseAssertion(expression); err == nil {
+			t.Errorf("parseAssertion(%q) = %+v, want an error", expression, got)
+		}
+	}
+}
+
+func TestAssertionHolds(t *testing.T) {
+	// whether each operator holds for a value below, equal to and above the threshold of 10
+	for operator, want := range map[string][3]bool{
+		"<":  {true, false, false},
+		"<=": {true, true, false},
+		">":  {false, false, true},
+		">=": {false, true, true},
+		"==": {false, true, false},
+		"!=": {true, false, true},
+	} {
+		a := assertion{metric: "p99_latency_ms", operator: operator, threshold: 10}
+		for i, value := range []float64{9.5, 10, 10.5} {
+			if got := a.holds(value); got != want[i] {
+				t.Errorf("%g %s 10 = %v, want %v", value, operator, got, want[i])
+			}
+		}
+	}
+}
+
+func TestParseAssertions(t *testing.T) {
+	config := &types.Configuration{}
+	config.Client.Assertions = map[string][]string{
+		"https_burst": {"p99_latency_ms < 20", "failure_rate < 0.001"},
+		"dns_matrix":  {"truncated_responses == 0"},
+	}
+	assertions, err := parseAssertions(config)
+	if err != nil {
+		t.Fatalf("parseAssertions failed: %v", err)
+	}
+	if len(assertions["https_burst"]) != 2 || assertions["https_burst"][1].metric != "failure_rate" || len(assertions["dns_matrix"]) != 1 {
+		t.Errorf("parseAssertions = %+v", assertions)
+	}
+
+	for _, c := range []struct {
+		name       string
+		assertions map[string][]string
+		comparison bool
+		want       string
+	}{
+		{"unknown test", map[string][]string{"https_bursts": {"p99_latency_ms < 20"}}, false, `unknown test "https_bursts"`},
+		{"malformed", map[string][]string{"ping": {"p99_latency_ms < 20", "loss_rate"}}, false, `ping: assertion "loss_rate"`},
+		{"comparison", map[string][]string{"ping": {"loss_rate < 0.01"}}, true, "comparison mode"},
+	} {
+		config := &types.Configuration{}
+		config.Client.Assertions = c.assertions
+		config.Client.Comparison.Enable = c.comparison
+		if _, err := parseAssertions(config); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: parseAssertions error %v, want one containing %q", c.name, err, c.want)
+		}
+	}
+
+	// a comparison run without assertions is valid
+	config = &types.Configuration{}
+	config.Client.Comparison.Enable = true
+	if _, err := parseAssertions(config); err != nil {
+		t.Errorf("parseAssertions of a comparison without assertions failed: %v", err)
+	}
+}
+
+func TestEvaluateAssertions(t *testing.T) {
+	directory := testResultsDirectory
+	testResultsDirectory = t.TempDir() + "/"
+	defer func() { testResultsDirectory = directory }()
+
+	parse := func(expressions ...string) []assertion {
+		var assertions []assertion
+		for _, expression := range expressions {
+			a, err := parseAssertion(expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertions = append(assertions, a)
+		}
+		return assertions
+	}
+	recorder := &resultRecorder{
+		test:    "https_burst",
+		started: time.Now(),
+		assertions: map[string][]assertion{
+			"https_burst": parse("p99_latency_ms < 20", "failure_rate < 0.01", "throughput_mbps > 100"),
+		},
+		steps: []stepRecord{
+			{Step: "10", Metrics: map[string]float64{"p99_latency_ms": 8, "failure_rate": 0}},
+			{Step: "100", Metrics: map[string]float64{"p99_latency_ms": 12, "failure_rate": 0.05}},
+		},
+	}
+	suite := recorder.evaluateAssertions()
+	if suite.Tests != 3 || suite.Failures != 2 {
+		t.Fatalf("https_burst suite has %d tests and %d failures, want 3 and 2", suite.Tests, suite.Failures)
+	}
+	if suite.TestCases[0].Failure != nil {
+		t.Errorf("%s failed: %s", suite.TestCases[0].Name, suite.TestCases[0].Failure.Message)
+	}
+	if failure := suite.TestCases[1].Failure; failure == nil || failure.Message != "failure_rate < 0.01 failed in 1 of 2 checks" || !strings.Contains(failure.Text, "step 100: failure_rate = 0.050") {
+		t.Errorf("failure_rate < 0.01 failure = %+v", failure)
+	}
+	if failure := suite.TestCases[2].Failure; failure == nil || !strings.Contains(failure.Message, "no step reported throughput_mbps") {
+		t.Errorf("throughput_mbps > 100 failure = %+v, want one because no step reported the metric", failure)
+	}
+
+	// a test without assertions has a single passing test case
+	recorder.test, recorder.steps = "ping", nil
+	if suite := recorder.evaluateAssertions(); suite.Tests != 1 || suite.Failures != 0 || suite.TestCases[0].Name != "run" {
+		t.Errorf("ping suite = %+v, want a single passing run", suite)
+	}
+
+	recorder.writeJUnitReport("-junit")
+	data, err := os.ReadFile(filepath.Join(testResultsDirectory, "-junit.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid JUnit report: %v\n%s", err, data)
+	}
+	if report.Tests != 4 || report.Failures != 2 || len(report.Suites) != 2 {
+		t.Errorf("JUnit report has %d tests, %d failures and %d suites, want 4, 2 and 2:\n%s", report.Tests, report.Failures, len(report.Suites), data)
+	}
+	if report.Suites[0].Name != "https_burst" || report.Suites[0].Failures != 2 || report.Suites[1].Name != "ping" || report.Suites[1].Failures != 0 {
+		t.Errorf("JUnit suites = %+v", report.Suites)
+	}
+}
@@ -3,7 +3,8 @@ package client
 import (
 	"encoding/csv"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
 	"os"
 	"strconv"
@@ -15,11 +16,13 @@ import (
 	"github.com/jrcamenzuli/network-performance-tester-client/tests"
 	"github.com/jrcamenzuli/network-performance-tester-client/types"
 	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"github.com/miekg/dns"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/mem"
 )
 
-const testResultsDirectory string = "test-results/"
+// testResultsDirectory is the directory of the run in progress, test-results/<run id>/
+var testResultsDirectory = "test-results/"
 
 // Helper function to generate dynamic CSV headers for process monitoring
 func generateProcessHeaders(baseHeaders []string, processNames []string) []string {
@@ -64,15 +67,101 @@ func generateProcessData(processUsage model.ProcessCpuAndRam, processNames []str
 	return processData
 }
 
+// Helper function to generate CSV headers for latency statistics
+func generateLatencyStatsHeaders(baseHeaders []string) []string {
+	headers := make([]string, len(baseHeaders))
+	copy(headers, baseHeaders)
+
+	return append(headers,
+		"count samples", "count lost", "loss (%)",
+		"min (ms)", "max (ms)", "mean (ms)", "stddev (ms)",
+		"p50 (ms)", "p90 (ms)", "p95 (ms)", "p99 (ms)",
+		"jitter RFC 3550 (ms)", "mean IPDV (ms)", "max IPDV (ms)")
+}
+
+// Helper function to generate latency statistics values for CSV
+func generateLatencyStatsData(stats model.LatencyStats) []string {
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.3f", durationToMilliseconds(d)) }
+	return []string{
+		strconv.Itoa(int(stats.CountSamples)),
+		strconv.Itoa(int(stats.CountLost)),
+		fmt.Sprintf("%.4f", stats.LossRate()*100.0),
+		ms(stats.Min), ms(stats.Max), ms(stats.Mean), ms(stats.StdDev),
+		ms(stats.P50), ms(stats.P90), ms(stats.P95), ms(stats.P99),
+		ms(stats.Jitter), ms(stats.IPDV_Mean), ms(stats.IPDV_Max),
+	}
+}
+
+// Helper function to generate CSV headers for DNS latency, split by whether the answer should have been cached
+func generateDnsLatencyHeaders(baseHeaders []string) []string {
+	headers := make([]string, len(baseHeaders))
+	copy(headers, baseHeaders)
+
+	return append(headers,
+		"p50 latency (ms)", "p99 latency (ms)",
+		"cached queries", "cached p50 latency (ms)", "cached p99 latency (ms)",
+		"uncached queries", "uncached p50 latency (ms)", "uncached p99 latency (ms)",
+		"proxy paths")
+}
+
+// Helper function to generate DNS latency values for CSV
+func generateDnsLatencyData(latency model.LatencyStats, cachedLatency model.LatencyStats, uncachedLatency model.LatencyStats, proxyPaths map[string]uint) []string {
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.3f", durationToMilliseconds(d)) }
+	return []string{
+		ms(latency.P50), ms(latency.P99),
+		strconv.Itoa(int(cachedLatency.CountSamples)), ms(cachedLatency.P50), ms(cachedLatency.P99),
+		strconv.Itoa(int(uncachedLatency.CountSamples)), ms(uncachedLatency.P50), ms(uncachedLatency.P99),
+		util.FormatCounts(proxyPaths),
+	}
+}
+
+// Helper function to generate CSV headers for HTTP request latency, negotiated protocols and failures
+func generateHTTPHeaders(baseHeaders []string) []string {
+	headers := make([]string, len(baseHeaders))
+	copy(headers, baseHeaders)
+
+	return append(headers,
+		"p50 latency (ms)", "p99 latency (ms)",
+		"protocols", "failure classes",
+		"QUIC fallbacks", "QUIC fallback cost (ms)",
+		"new connections", "reused connections",
+		"proxy paths")
+}
+
+// Helper function to generate HTTP request values for CSV
+func generateHTTPData(latency model.LatencyStats, protocols map[string]uint, failures model.FailureClasses, countFallbacks uint, fallbackCost time.Duration, countNewConnections uint, countReusedConnections uint, proxyPaths map[string]uint) []string {
+	return []string{
+		fmt.Sprintf("%.3f", durationToMilliseconds(latency.P50)),
+		fmt.Sprintf("%.3f", durationToMilliseconds(latency.P99)),
+		util.FormatCounts(protocols),
+		util.FormatCounts(failures),
+		strconv.Itoa(int(countFallbacks)),
+		fmt.Sprintf("%.3f", durationToMilliseconds(fallbackCost)),
+		strconv.Itoa(int(countNewConnections)),
+		strconv.Itoa(int(countReusedConnections)),
+		util.FormatCounts(proxyPaths),
+	}
+}
+
+func formatLatencyStats(stats model.LatencyStats) string {
+	return fmt.Sprintf("min/mean/max/stddev = %.3f/%.3f/%.3f/%.3fms, p99 %.3fms, %d/%d lost",
+		durationToMilliseconds(stats.Min), durationToMilliseconds(stats.Mean), durationToMilliseconds(stats.Max),
+		durationToMilliseconds(stats.StdDev), durationToMilliseconds(stats.P99), stats.CountLost, stats.CountSamples)
+}
+
+func durationToMilliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
 func createLogFile(filename string, contents func(*csv.Writer)) {
 	f, err := os.Create(filename)
 	if err != nil {
-		fmt.Println("Could not create " + filename)
+		slog.Error("Could not create a results file", "file", filename, "error", err)
 	}
 	defer f.Close()
 
 	if err != nil {
-		log.Fatalln("failed to open file", err)
+		fatal("failed to open file", err)
 	}
 
 	w := csv.NewWriter(f)
@@ -81,34 +170,102 @@ func createLogFile(filename string, contents func(*csv.Writer)) {
 	contents(w)
 }
 
-func RunClient(config *types.Configuration) {
+// RunClient runs the enabled tests and returns false if the configuration is invalid or an assertion failed
+func RunClient(config *types.Configuration) (passed bool) {
 
 	logfilePrefix := strings.Replace(strings.Replace(time.Now().UTC().Format(time.RFC3339), ":", "", -1), "-", "", -1)
+	run := newManifest(logfilePrefix, config)
+	testResultsDirectory = "test-results/" + logfilePrefix + "/"
+	if err := os.MkdirAll(testResultsDirectory, 0700); err != nil {
+		slog.Error("Could not create the run directory", "directory", testResultsDirectory, "error", err)
+		return false
+	}
+	var store *resultStore
+	defer func() {
+		run.write(testResultsDirectory, passed, recorder.tests)
+		if store != nil {
+			if err := store.finish(run); err != nil {
+				slog.Error("Could not record the end of the run in the result store", "store", store.path, "error", err)
+			}
+		}
+	}()
 	config.Client.LogfilePostfix = "-" + config.Client.LogfilePostfix
+	var logFile io.Writer
+	if f, err := os.Create(testResultsDirectory + logfilePrefix + "-log" + config.Client.LogfilePostfix + ".log"); err != nil {
+		slog.Error("Could not create the log file, the run will be logged to the console only", "error", err)
+	} else {
+		defer f.Close()
+		logFile = f
+	}
+	stopLogging, err := util.SetupLogging(config.Client.Logging, logFile)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		run.Status = "invalid"
+		return false
+	}
+	defer stopLogging()
+	if config.Client.StabilityThreshold == 0 {
+		config.Client.StabilityThreshold = 10
+	}
 
 	logConfigInfo(logfilePrefix, config)
 
-	logDeviceInfo(logfilePrefix, config.Client.LogfilePostfix)
+	run.Device = logDeviceInfo(logfilePrefix, config.Client.LogfilePostfix)
+
+	dnsQueryName, dnsQueryTypes := dnsQuery(config)
+
+	proxy, dnsProxy, err := proxySelectors(config)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		run.Status = "invalid"
+		return false
+	}
+
+	recorder.assertions, err = parseAssertions(config)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		run.Status = "invalid"
+		return false
+	}
+	if config.Client.Comparison.Enable {
+		runComparison(logfilePrefix, config, dnsQueryName, dnsQueryTypes)
+		return true
+	}
+	label := strings.TrimPrefix(config.Client.LogfilePostfix, "-")
+	exporters := newExporters(logfilePrefix, config)
+	if !config.Client.Store.Disable {
+		if store, err = openStore(config.Client.Store.Path, run, label); err != nil {
+			slog.Error("Could not open the result store, the run will not be recorded in it", "error", err)
+		} else {
+			exporters = append(exporters, store)
+		}
+	}
+	recorder.open(logfilePrefix, label, logfilePrefix+"-results"+config.Client.LogfilePostfix, exporters, config.Client.ProcessNames)
 
 	if config.Client.Tests.IdleStateOfDevice.Enable {
+		recorder.startTest("idle_state_of_device")
 		testIdleStateOfDevice(logfilePrefix, config.Client.LogfilePostfix)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.IdleStateOfProcess.Enable {
+		recorder.startTest("idle_state_of_process")
 		// Support both new process names and legacy PID for backward compatibility
 		if len(config.Client.ProcessNames) > 0 {
 			testIdleStateOfProcesses(logfilePrefix, config.Client.LogfilePostfix, config.Client.ProcessNames)
 		} else if config.Client.PID > 0 {
 			testIdleStateOfProcess(logfilePrefix, config.Client.LogfilePostfix, config.Client.PID)
 		} else {
-			fmt.Printf(util.WarningColor, "\nNo processes specified for monitoring. Please set either 'process_names' or 'pid' in config.\n\n")
+			slog.Warn("No processes specified for monitoring. Please set either 'process_names' or 'pid' in config.")
 		}
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.HTTP_Throughput.Enable {
-		fmt.Println("Starting HTTP Throughput Test")
+		slog.Info("Starting HTTP Throughput Test")
+		recorder.startTest("http_throughput")
 		testHTTP_Throughput(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
@@ -116,12 +273,17 @@ func RunClient(config *types.Configuration) {
 			config.Client.ServerTCP_HTTP_Port,
 			config.Client.PID,
 			false,
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			util.HTTPClientOptions{Proxy: proxy, LocalAddress: config.Client.LocalAddress},
+			config.Client.Tests.HTTP_Throughput.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.HTTPS_Throughput.Enable {
-		fmt.Println("Starting HTTPS Throughput Test")
+		slog.Info("Starting HTTPS Throughput Test")
+		recorder.startTest("https_throughput")
 		testHTTP_Throughput(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
@@ -129,34 +291,101 @@ func RunClient(config *types.Configuration) {
 			config.Client.ServerTCP_HTTPS_Port,
 			config.Client.PID,
 			true,
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			util.HTTPClientOptions{Proxy: proxy, LocalAddress: config.Client.LocalAddress},
+			config.Client.Tests.HTTPS_Throughput.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+
+	if config.Client.Tests.HTTP3_Throughput.Enable {
+		slog.Info("Starting HTTP/3 Throughput Test")
+		recorder.startTest("http3_throughput")
+		testHTTP_Throughput(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.ServerTCP_HTTPS_Port,
+			config.Client.PID,
+			true,
+			config.Client.ProcessNames,
+			util.HTTPClientOptions{Protocol: "h3", HTTP3Fallback: config.Client.Tests.HTTP3_Throughput.Fallback},
+			config.Client.Tests.HTTP3_Throughput.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.Ping.Enable {
-		fmt.Println("Starting Ping Test")
+		slog.Info("Starting Ping Test")
+		recorder.startTest("ping")
 		testPing(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
 			config.Client.ServerHost,
 			config.Client.ServerPingPort,
-			config.Client.Tests.Ping.CountSamples)
+			config.Client.LocalAddress,
+			config.Client.Tests.Ping.CountSamples,
+			config.Client.Tests.Ping.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+
+	if config.Client.Tests.ICMP_Ping.Enable {
+		slog.Info("Starting ICMP Ping Test")
+		recorder.startTest("icmp_ping")
+		testICMP_Ping(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.Tests.ICMP_Ping.CountSamples,
+			config.Client.Tests.ICMP_Ping.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+
+	if config.Client.Tests.TCP_Ping.Enable {
+		slog.Info("Starting TCP Ping Test")
+		recorder.startTest("tcp_ping")
+		ports := config.Client.Tests.TCP_Ping.Ports
+		if len(ports) == 0 {
+			ports = []uint{config.Client.ServerTCP_HTTP_Port, config.Client.ServerTCP_HTTPS_Port} // default ports if none specified
+		}
+		testTCP_Ping(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			ports,
+			config.Client.ServerTCP_EchoPort,
+			config.Client.Tests.TCP_Ping.CountSamples,
+			config.Client.Tests.TCP_Ping.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.Jitter.Enable {
-		fmt.Println("Starting Jitter Test")
+		slog.Info("Starting Jitter Test")
+		recorder.startTest("jitter")
 		testJitter(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
 			config.Client.ServerHost,
 			config.Client.ServerPingPort,
-			config.Client.Tests.Jitter.CountDifferences)
+			config.Client.LocalAddress,
+			config.Client.Tests.Jitter.CountDifferences,
+			config.Client.Tests.Jitter.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.HTTP_Burst.Enable {
-		fmt.Println("Starting HTTP Burst Test")
+		slog.Info("Starting HTTP Burst Test")
+		recorder.startTest("http_burst")
 		testHTTP_Burst(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
@@ -165,12 +394,18 @@ func RunClient(config *types.Configuration) {
 			10, // countTestsToRun
 			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
 			false,
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			httpClientOptions(config.Client.Tests.HTTP_Burst.Protocol, config.Client.Tests.HTTP_Burst.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTP_Burst.ConnectionPolicy),
+			httpCompareOptions(config.Client.Tests.HTTP_Burst.Protocol, config.Client.Tests.HTTP_Burst.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTP_Burst.ComparePolicy),
+			config.Client.Tests.HTTP_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.HTTPS_Burst.Enable {
-		fmt.Println("Starting HTTPS Burst Test")
+		slog.Info("Starting HTTPS Burst Test")
+		recorder.startTest("https_burst")
 		testHTTP_Burst(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
@@ -179,12 +414,38 @@ func RunClient(config *types.Configuration) {
 			10, // countTestsToRun
 			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
 			true,
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			httpClientOptions(config.Client.Tests.HTTPS_Burst.Protocol, config.Client.Tests.HTTPS_Burst.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTPS_Burst.ConnectionPolicy),
+			httpCompareOptions(config.Client.Tests.HTTPS_Burst.Protocol, config.Client.Tests.HTTPS_Burst.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTPS_Burst.ComparePolicy),
+			config.Client.Tests.HTTPS_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+
+	if config.Client.Tests.HTTP3_Burst.Enable {
+		slog.Info("Starting HTTP/3 Burst Test")
+		recorder.startTest("http3_burst")
+		testHTTP_Burst(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.ServerTCP_HTTPS_Port,
+			10, // countTestsToRun
+			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
+			true,
+			config.Client.ProcessNames,
+			util.HTTPClientOptions{Protocol: "h3", HTTP3Fallback: config.Client.Tests.HTTP3_Burst.Fallback},
+			nil,
+			config.Client.Tests.HTTP3_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 
 	if config.Client.Tests.HTTP_Rate.Enable {
-		fmt.Println("Starting HTTP Rate Test")
+		slog.Info("Starting HTTP Rate Test")
+		recorder.startTest("http_rate")
 		rates := config.Client.Tests.HTTP_Rate.Rates
 		if len(rates) == 0 {
 			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
@@ -199,11 +460,17 @@ func RunClient(config *types.Configuration) {
 			func(i int) int { return rates[i] },
 			time.Second*time.Duration(config.Client.Tests.HTTP_Rate.Duration), // testDuration
 			config.Client.PID,
-			false)
+			false,
+			httpClientOptions(config.Client.Tests.HTTP_Rate.Protocol, config.Client.Tests.HTTP_Rate.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTP_Rate.ConnectionPolicy),
+			httpCompareOptions(config.Client.Tests.HTTP_Rate.Protocol, config.Client.Tests.HTTP_Rate.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTP_Rate.ComparePolicy),
+			config.Client.Tests.HTTP_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 	if config.Client.Tests.HTTPS_Rate.Enable {
-		fmt.Println("Starting HTTPS Rate Test")
+		slog.Info("Starting HTTPS Rate Test")
+		recorder.startTest("https_rate")
 		rates := config.Client.Tests.HTTPS_Rate.Rates
 		if len(rates) == 0 {
 			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
@@ -218,39 +485,182 @@ func RunClient(config *types.Configuration) {
 			func(i int) int { return rates[i] },
 			time.Second*time.Duration(config.Client.Tests.HTTPS_Rate.Duration), // testDuration
 			config.Client.PID,
-			true)
+			true,
+			httpClientOptions(config.Client.Tests.HTTPS_Rate.Protocol, config.Client.Tests.HTTPS_Rate.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTPS_Rate.ConnectionPolicy),
+			httpCompareOptions(config.Client.Tests.HTTPS_Rate.Protocol, config.Client.Tests.HTTPS_Rate.MaxStreams, proxy, config.Client.LocalAddress, config.Client.Tests.HTTPS_Rate.ComparePolicy),
+			config.Client.Tests.HTTPS_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.HTTP3_Rate.Enable {
+		slog.Info("Starting HTTP/3 Rate Test")
+		recorder.startTest("http3_rate")
+		rates := config.Client.Tests.HTTP3_Rate.Rates
+		if len(rates) == 0 {
+			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+		}
+		testHTTP_Rate(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.ServerTCP_HTTPS_Port,
+			time.Second*5, // restDuration
+			len(rates),    // countTestsToRun
+			func(i int) int { return rates[i] },
+			time.Second*time.Duration(config.Client.Tests.HTTP3_Rate.Duration), // testDuration
+			config.Client.PID,
+			true,
+			util.HTTPClientOptions{Protocol: "h3", HTTP3Fallback: config.Client.Tests.HTTP3_Rate.Fallback},
+			nil,
+			config.Client.Tests.HTTP3_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.GRPC.Enable {
+		slog.Info("Starting gRPC Test")
+		recorder.startTest("grpc")
+		grpcConfig := config.Client.Tests.GRPC
+		rates := grpcConfig.Rates
+		if len(rates) == 0 {
+			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+		}
+		messageSize := grpcConfig.MessageSize
+		if messageSize == 0 {
+			messageSize = 1024
+		}
+		streamBytes := grpcConfig.StreamBytes
+		if streamBytes == 0 {
+			streamBytes = 100000000 // 100MB
+		}
+		countMessages := grpcConfig.CountMessages
+		if countMessages == 0 {
+			countMessages = 100
+		}
+		testDuration := grpcConfig.Duration
+		if testDuration == 0 {
+			testDuration = 10
+		}
+		testGRPC(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.ServerTCP_GRPC_Port,
+			grpcConfig.Secure,
+			time.Second*5, // restDuration
+			rates,
+			time.Second*time.Duration(testDuration),
+			messageSize,
+			streamBytes,
+			countMessages,
+			config.Client.ProcessNames,
+			grpcConfig.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.WS_Echo.Enable {
+		slog.Info("Starting WebSocket Test")
+		recorder.startTest("ws_echo")
+		messageSizes := config.Client.Tests.WS_Echo.MessageSizes
+		if len(messageSizes) == 0 {
+			messageSizes = []uint{16, 1024, 65536} // default message sizes if none specified
+		}
+		countMessages := config.Client.Tests.WS_Echo.CountMessages
+		if countMessages == 0 {
+			countMessages = 100
+		}
+		testDuration := config.Client.Tests.WS_Echo.Duration
+		if testDuration == 0 {
+			testDuration = 10
+		}
+		testWebSocket(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.ServerTCP_HTTP_Port,
+			false,
+			messageSizes,
+			countMessages,
+			time.Second*time.Duration(testDuration),
+			proxy,
+			config.Client.ProcessNames,
+			config.Client.Tests.WS_Echo.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.WSS_Echo.Enable {
+		slog.Info("Starting Secure WebSocket Test")
+		recorder.startTest("wss_echo")
+		messageSizes := config.Client.Tests.WSS_Echo.MessageSizes
+		if len(messageSizes) == 0 {
+			messageSizes = []uint{16, 1024, 65536} // default message sizes if none specified
+		}
+		countMessages := config.Client.Tests.WSS_Echo.CountMessages
+		if countMessages == 0 {
+			countMessages = 100
+		}
+		testDuration := config.Client.Tests.WSS_Echo.Duration
+		if testDuration == 0 {
+			testDuration = 10
+		}
+		testWebSocket(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			config.Client.ServerHost,
+			config.Client.ServerTCP_HTTPS_Port,
+			true,
+			messageSizes,
+			countMessages,
+			time.Second*time.Duration(testDuration),
+			proxy,
+			config.Client.ProcessNames,
+			config.Client.Tests.WSS_Echo.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 	if config.Client.Tests.DNS_UDP_Burst.Enable {
-		fmt.Println("Starting DNS over UDP Burst Test")
+		slog.Info("Starting DNS over UDP Burst Test")
+		recorder.startTest("dns_udp_burst")
 		testDNS_Burst(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
-			config.Client.ServerHost,
-			config.Client.ServerUDP_DNS_Port,
+			dnsTransport(config, "udp", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
 			time.Second*5, // restDuration
 			10,            // countTestsToRun
 			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
-			"udp",
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_UDP_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 	if config.Client.Tests.DNS_TCP_Burst.Enable {
-		fmt.Println("Starting DNS over TCP Burst Test")
+		slog.Info("Starting DNS over TCP Burst Test")
+		recorder.startTest("dns_tcp_burst")
 		testDNS_Burst(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
-			config.Client.ServerHost,
-			config.Client.ServerTCP_DNS_Port,
+			dnsTransport(config, "tcp", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
 			time.Second*5, // restDuration
 			10,            // countTestsToRun
 			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
-			"tcp",
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_TCP_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 	if config.Client.Tests.DNS_UDP_Rate.Enable {
-		fmt.Println("Starting DNS over UDP Rate Test")
+		slog.Info("Starting DNS over UDP Rate Test")
+		recorder.startTest("dns_udp_rate")
 		rates := config.Client.Tests.DNS_UDP_Rate.Rates
 		if len(rates) == 0 {
 			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
@@ -258,19 +668,23 @@ func RunClient(config *types.Configuration) {
 		testDNS_Rate(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
-			config.Client.ServerHost,
-			config.Client.ServerUDP_DNS_Port,
+			dnsTransport(config, "udp", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
 			time.Second*5, // restDuration
 			len(rates),    // countTestsToRun
 			func(i int) int { return rates[i] },
 			time.Second*time.Duration(config.Client.Tests.DNS_UDP_Rate.Duration), // testDuration
 			config.Client.PID,
-			"udp",
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_UDP_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
 	if config.Client.Tests.DNS_TCP_Rate.Enable {
-		fmt.Println("Starting DNS over TCP Rate Test")
+		slog.Info("Starting DNS over TCP Rate Test")
+		recorder.startTest("dns_tcp_rate")
 		rates := config.Client.Tests.DNS_TCP_Rate.Rates
 		if len(rates) == 0 {
 			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
@@ -278,57 +692,449 @@ func RunClient(config *types.Configuration) {
 		testDNS_Rate(
 			logfilePrefix,
 			config.Client.LogfilePostfix,
-			config.Client.ServerHost,
-			config.Client.ServerTCP_DNS_Port,
+			dnsTransport(config, "tcp", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
 			time.Second*5, // restDuration
 			len(rates),    // countTestsToRun
 			func(i int) int { return rates[i] },
 			time.Second*time.Duration(config.Client.Tests.DNS_TCP_Rate.Duration), // testDuration
 			config.Client.PID,
-			"tcp",
-			config.Client.ProcessNames)
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_TCP_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.DNS_DoT_Burst.Enable {
+		slog.Info("Starting DNS over TLS Burst Test")
+		recorder.startTest("dns_dot_burst")
+		testDNS_Burst(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, "tcp-tls", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
+			time.Second*5, // restDuration
+			10,            // countTestsToRun
+			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_DoT_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.DNS_DoT_Rate.Enable {
+		slog.Info("Starting DNS over TLS Rate Test")
+		recorder.startTest("dns_dot_rate")
+		rates := config.Client.Tests.DNS_DoT_Rate.Rates
+		if len(rates) == 0 {
+			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+		}
+		testDNS_Rate(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, "tcp-tls", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
+			time.Second*5, // restDuration
+			len(rates),    // countTestsToRun
+			func(i int) int { return rates[i] },
+			time.Second*time.Duration(config.Client.Tests.DNS_DoT_Rate.Duration), // testDuration
+			config.Client.PID,
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_DoT_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.DNS_DoH_Burst.Enable {
+		slog.Info("Starting DNS over HTTPS Burst Test")
+		recorder.startTest("dns_doh_burst")
+		testDNS_Burst(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, dohTransport(config.Client.Tests.DNS_DoH_Burst.Method), dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
+			time.Second*5, // restDuration
+			10,            // countTestsToRun
+			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_DoH_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
 		time.Sleep(time.Second * 5)
 	}
+	if config.Client.Tests.DNS_DoH_Rate.Enable {
+		slog.Info("Starting DNS over HTTPS Rate Test")
+		recorder.startTest("dns_doh_rate")
+		rates := config.Client.Tests.DNS_DoH_Rate.Rates
+		if len(rates) == 0 {
+			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+		}
+		testDNS_Rate(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, dohTransport(config.Client.Tests.DNS_DoH_Rate.Method), dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
+			time.Second*5, // restDuration
+			len(rates),    // countTestsToRun
+			func(i int) int { return rates[i] },
+			time.Second*time.Duration(config.Client.Tests.DNS_DoH_Rate.Duration), // testDuration
+			config.Client.PID,
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_DoH_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.DNS_DoQ_Burst.Enable {
+		slog.Info("Starting DNS over QUIC Burst Test")
+		recorder.startTest("dns_doq_burst")
+		testDNS_Burst(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, "quic", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
+			time.Second*5, // restDuration
+			10,            // countTestsToRun
+			func(i int) int { return (i + 1) * 10 }, config.Client.PID,
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_DoQ_Burst.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.DNS_DoQ_Rate.Enable {
+		slog.Info("Starting DNS over QUIC Rate Test")
+		recorder.startTest("dns_doq_rate")
+		rates := config.Client.Tests.DNS_DoQ_Rate.Rates
+		if len(rates) == 0 {
+			rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+		}
+		testDNS_Rate(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, "quic", dnsProxy),
+			dnsNameSource(config, dnsQueryName),
+			dnsQueryTypes,
+			time.Second*5, // restDuration
+			len(rates),    // countTestsToRun
+			func(i int) int { return rates[i] },
+			time.Second*time.Duration(config.Client.Tests.DNS_DoQ_Rate.Duration), // testDuration
+			config.Client.PID,
+			config.Client.ProcessNames,
+			config.Client.Tests.DNS_DoQ_Rate.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+	if config.Client.Tests.DNS_Matrix.Enable {
+		slog.Info("Starting DNS Matrix Test")
+		recorder.startTest("dns_matrix")
+		matrix := config.Client.Tests.DNS_Matrix
+		transportProtocol := matrix.Transport
+		if transportProtocol == "" {
+			transportProtocol = "udp"
+		}
+		queryTypes := dnsQueryTypes
+		if len(matrix.QueryTypes) > 0 {
+			queryTypes = parseDnsQueryTypes(matrix.QueryTypes)
+		}
+		responseSizes := matrix.ResponseSizes
+		if len(responseSizes) == 0 {
+			responseSizes = []uint{0} // the server's natural answer if no sizes specified
+		}
+		countQueries := matrix.CountQueries
+		if countQueries == 0 {
+			countQueries = 20
+		}
+		testDNS_Matrix(
+			logfilePrefix,
+			config.Client.LogfilePostfix,
+			dnsTransport(config, transportProtocol, dnsProxy),
+			dnsQueryName,
+			queryTypes,
+			responseSizes,
+			countQueries,
+			matrix.EDNS_BufferSize,
+			matrix.Repetitions,
+			config.Client.StabilityThreshold)
+		recorder.endTest()
+		time.Sleep(time.Second * 5)
+	}
+
+	recorder.close()
+	recorder.writeJUnitReport(logfilePrefix + "-junit" + config.Client.LogfilePostfix)
+	recorder.writeSummary(logfilePrefix + "-summary" + config.Client.LogfilePostfix)
+	if recorder.countFailures > 0 {
+		slog.Error(fmt.Sprintf("%d assertion(s) failed", recorder.countFailures))
+		return false
+	}
+	return true
+}
+
+// proxySelectors returns the proxy of the HTTP tests and the proxy of DNS over TCP, which is nil unless it is also proxied,
+// and checks the local address
+func proxySelectors(config *types.Configuration) (*util.ProxySelector, *util.ProxySelector, error) {
+	if config.Client.LocalAddress != "" && net.ParseIP(config.Client.LocalAddress) == nil {
+		return nil, nil, fmt.Errorf("local address %q is not an IP address", config.Client.LocalAddress)
+	}
+	proxy, err := util.NewProxySelector(util.ProxyOptions{
+		URL:         config.Client.Proxy.URL,
+		PAC:         config.Client.Proxy.PAC,
+		Username:    config.Client.Proxy.Username,
+		Password:    config.Client.Proxy.Password,
+		Direct:      config.Client.Proxy.Direct,
+		Environment: config.Client.Proxy.Environment,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.Client.Proxy.DNS_OverTCP {
+		return proxy, proxy, nil
+	}
+	return proxy, nil, nil
+}
+
+// dnsQuery returns the configured name and record types that the DNS tests query
+func dnsQuery(config *types.Configuration) (string, []uint16) {
+	queryName := config.Client.DNS.QueryName
+	if queryName == "" {
+		queryName = "test.service"
+	}
+	queryTypes := parseDnsQueryTypes(config.Client.DNS.QueryTypes)
+	return queryName, queryTypes
+}
+
+// dnsTransport creates the transport for a DNS test to the port configured for it, or returns nil if it is misconfigured
+func dnsTransport(config *types.Configuration, transportProtocol string, proxy *util.ProxySelector) *tests.DnsTransport {
+	var serverPort uint
+	switch transportProtocol {
+	case "udp":
+		serverPort = config.Client.ServerUDP_DNS_Port
+	case "tcp":
+		serverPort = config.Client.ServerTCP_DNS_Port
+	case "tcp-tls":
+		serverPort = config.Client.ServerTCP_DoT_Port
+	case "https-get", "https-post":
+		serverPort = config.Client.ServerTCP_DoH_Port
+	case "quic":
+		serverPort = config.Client.ServerUDP_DoQ_Port
+	}
+	transport, err := tests.NewDnsTransport(transportProtocol, config.Client.ServerHost, serverPort, config.Client.DNS.DoH_Path, config.Client.LocalAddress, proxy)
+	if err != nil {
+		slog.Error("Skipping DNS test", "error", err)
+		return nil
+	}
+	return transport
+}
+
+// dohTransport returns the DNS over HTTPS transport for a request method, defaulting to POST
+func dohTransport(method string) string {
+	if strings.EqualFold(method, "get") {
+		return "https-get"
+	}
+	return "https-post"
+}
+
+// dnsTestName returns the name of a DNS test used in its log file name, e.g. "-dnsUdpBurstTest"
+func dnsTestName(transportProtocol string, kind string) string {
+	transportNames := map[string]string{
+		"udp":        "Udp",
+		"tcp":        "Tcp",
+		"tcp-tls":    "Dot",
+		"https-get":  "DohGet",
+		"https-post": "DohPost",
+		"quic":       "Doq",
+	}
+	return "-dns" + transportNames[transportProtocol] + kind + "Test"
+}
+
+// httpStep is the outcome of one step of an HTTP burst or rate test that is compared between connection policies
+type httpStep struct {
+	duration               time.Duration // burst only
+	failureRate            float64
+	latency                model.LatencyStats
+	countNewConnections    uint
+	countReusedConnections uint
+}
+
+// policyComparison is one step of an HTTP test run under connection policies a and b, in alternating order
+type policyComparison struct {
+	step int
+	a    httpStep
+	b    httpStep
+}
+
+// writePolicyComparison writes <name>.csv with the results of each step under both connection policies and their deltas
+func writePolicyComparison(name string, stepHeader string, policyA util.HTTPClientOptions, policyB util.HTTPClientOptions, comparisons []policyComparison) {
+	fmt.Printf("Connection policy A: %s\nConnection policy B: %s\n", formatConnectionPolicy(policyA), formatConnectionPolicy(policyB))
+	createLogFile(testResultsDirectory+name+".csv", func(w *csv.Writer) {
+		w.Write([]string{stepHeader, "policy A", "policy B",
+			"A time to complete (ms)", "B time to complete (ms)", "time to complete delta (%)",
+			"A failure rate (%)", "B failure rate (%)",
+			"A p50 latency (ms)", "B p50 latency (ms)", "p50 latency delta (%)",
+			"A p99 latency (ms)", "B p99 latency (ms)", "p99 latency delta (%)",
+			"A new connections", "B new connections", "A reused connections", "B reused connections"})
+		for _, comparison := range comparisons {
+			a, b := comparison.a, comparison.b
+			timeA, timeB, timeDelta := "N/A", "N/A", "N/A"
+			if a.duration != 0 || b.duration != 0 {
+				timeA = strconv.Itoa(int(a.duration.Milliseconds()))
+				timeB = strconv.Itoa(int(b.duration.Milliseconds()))
+				timeDelta = deltaPercent(durationToMilliseconds(a.duration), durationToMilliseconds(b.duration))
+			}
+			p99Delta := deltaPercent(durationToMilliseconds(a.latency.P99), durationToMilliseconds(b.latency.P99))
+			fmt.Printf("%s %d: p99 latency %.3fms vs %.3fms (%s%%), new connections %d vs %d\n", stepHeader, comparison.step,
+				durationToMilliseconds(a.latency.P99), durationToMilliseconds(b.latency.P99), p99Delta, a.countNewConnections, b.countNewConnections)
+			w.Write([]string{
+				strconv.Itoa(comparison.step), formatConnectionPolicy(policyA), formatConnectionPolicy(policyB),
+				timeA, timeB, timeDelta,
+				fmt.Sprintf("%.2f", a.failureRate*100), fmt.Sprintf("%.2f", b.failureRate*100),
+				fmt.Sprintf("%.3f", durationToMilliseconds(a.latency.P50)), fmt.Sprintf("%.3f", durationToMilliseconds(b.latency.P50)),
+				deltaPercent(durationToMilliseconds(a.latency.P50), durationToMilliseconds(b.latency.P50)),
+				fmt.Sprintf("%.3f", durationToMilliseconds(a.latency.P99)), fmt.Sprintf("%.3f", durationToMilliseconds(b.latency.P99)),
+				p99Delta,
+				strconv.Itoa(int(a.countNewConnections)), strconv.Itoa(int(b.countNewConnections)),
+				strconv.Itoa(int(a.countReusedConnections)), strconv.Itoa(int(b.countReusedConnections)),
+			})
+			w.Flush()
+		}
+	})
+}
+
+// deltaPercent returns the change from a to b as a percentage of a, or N/A if a is 0
+func deltaPercent(a float64, b float64) string {
+	if a == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%+.2f", (b-a)/a*100)
+}
+
+// formatConnectionPolicy describes the connection reuse policy of options, e.g. "keep-alive max-idle=100 prewarm=10"
+func formatConnectionPolicy(options util.HTTPClientOptions) string {
+	keepAlive := "keep-alive"
+	if options.DisableKeepAlives || options.Protocol == "http1-close" {
+		keepAlive = "no-keep-alive"
+	}
+	maxIdle := "default"
+	if options.MaxIdleConnsPerHost > 0 {
+		maxIdle = strconv.Itoa(options.MaxIdleConnsPerHost)
+	}
+	return fmt.Sprintf("%s max-idle=%s prewarm=%d", keepAlive, maxIdle, options.PrewarmConnections)
+}
+
+// httpClientOptions returns the client options of an HTTP test with the given protocol and connection policy
+func httpClientOptions(protocol string, maxStreams uint, proxy *util.ProxySelector, localAddress string, policy types.ConnectionPolicy) util.HTTPClientOptions {
+	return util.HTTPClientOptions{
+		Protocol:            protocol,
+		MaxStreams:          maxStreams,
+		Proxy:               proxy,
+		LocalAddress:        localAddress,
+		DisableKeepAlives:   policy.DisableKeepAlives,
+		MaxIdleConnsPerHost: policy.MaxIdleConnsPerHost,
+		PrewarmConnections:  policy.Prewarm,
+	}
+}
+
+// httpCompareOptions returns the client options of the connection policy an HTTP test is compared with, or nil if it has none
+func httpCompareOptions(protocol string, maxStreams uint, proxy *util.ProxySelector, localAddress string, policy *types.ConnectionPolicy) *util.HTTPClientOptions {
+	if policy == nil {
+		return nil
+	}
+	options := httpClientOptions(protocol, maxStreams, proxy, localAddress, *policy)
+	return &options
+}
+
+// httpTestName returns the name of an HTTP test used in its log file name, e.g. "-httpsBurstTest" or "-httpH2RateTest"
+func httpTestName(isHttps bool, clientOptions util.HTTPClientOptions, kind string) string {
+	protocolNames := map[string]string{
+		"http1":       "Http1",
+		"http1-close": "Http1Close",
+		"h2":          "H2",
+	}
+	switch {
+	case clientOptions.Protocol == "h3":
+		return "-http3" + kind + "Test"
+	case isHttps:
+		return "-https" + protocolNames[clientOptions.Protocol] + kind + "Test"
+	default:
+		return "-http" + protocolNames[clientOptions.Protocol] + kind + "Test"
+	}
+}
+
+// dnsNameSource creates the source of names queried by a DNS test, or returns nil if it is misconfigured
+func dnsNameSource(config *types.Configuration, queryName string) *tests.DnsNameSource {
+	dnsConfig := config.Client.DNS
+	names, err := tests.NewDnsNameSource(dnsConfig.NameMode, queryName, dnsConfig.DomainListFile, dnsConfig.RevisitRatio)
+	if err != nil {
+		slog.Error("Skipping DNS test", "error", err)
+		return nil
+	}
+	return names
+}
+
+// parseDnsQueryTypes parses record type names, defaulting to A if none are valid
+func parseDnsQueryTypes(names []string) []uint16 {
+	var queryTypes []uint16
+	for _, name := range names {
+		parsed, err := tests.ParseDnsQueryTypes([]string{name})
+		if err != nil {
+			slog.Warn("Ignoring DNS query type", "error", err)
+			continue
+		}
+		queryTypes = append(queryTypes, parsed...)
+	}
+	if len(queryTypes) == 0 {
+		queryTypes = []uint16{dns.TypeA}
+	}
+	return queryTypes
 }
 
 func logConfigInfo(logfilePrefix string, config *types.Configuration) {
 	postfix := config.Client.LogfilePostfix
-	f, err := os.Create("./test-results/" + logfilePrefix + "-configInfo" + postfix + ".txt")
-	defer f.Close()
+	f, err := os.Create(testResultsDirectory + logfilePrefix + "-configInfo" + postfix + ".txt")
 	if err != nil {
-		panic(err)
+		fatal("failed to create the config info file", err)
 	}
-	out := util.PrettifyStruct(config)
+	defer f.Close()
+	out := util.PrettifyStruct(util.RedactConfig(*config))
 	fmt.Printf("Config:\n%s\n\n", out)
 	f.Write([]byte(out))
 }
 
-func logDeviceInfo(logfilePrefix string, logfilePostfix string) {
+func logDeviceInfo(logfilePrefix string, logfilePostfix string) *model.DUT_Info {
 	cpus, err := cpu.Info() // the CPU description
 	if err != nil || len(cpus) <= 0 {
-		str := fmt.Sprintf("\nI could not retrieve device information to log.\n\n")
-		fmt.Printf(util.WarningColor, str)
-		return
+		slog.Warn("I could not retrieve device information to log.", "error", err)
+		return nil
 	}
 	cpu := cpus[0]
 	mem, _ := mem.VirtualMemory() // the system memory description
 	deviceInfo := model.DUT_Info{CPU_ModelName: cpu.ModelName, CPU_CoreCount: uint(cpu.Cores), CPU_BaseClockFrequency: uint(cpu.Mhz) * 1e6, RAM_Total: uint(mem.Total)}
-	f, err := os.Create("./test-results/" + logfilePrefix + "-deviceInfo" + logfilePostfix + ".txt")
-	defer f.Close()
+	f, err := os.Create(testResultsDirectory + logfilePrefix + "-deviceInfo" + logfilePostfix + ".txt")
 	if err != nil {
-		panic(err)
+		fatal("failed to create the device info file", err)
 	}
+	defer f.Close()
 	out := util.PrettifyStruct(deviceInfo)
 	fmt.Printf("Device Info:\n%s\n\n", out)
 	f.Write([]byte(out))
+	return &deviceInfo
 }
 
 // Idle state test of device
 func testIdleStateOfDevice(logfilePrefix string, logfilePostfix string) {
 	filename := testResultsDirectory + logfilePrefix + "-idleStateOfDevice" + logfilePostfix + ".csv"
 	time.Sleep(1 * time.Second)
+	recorder.startStep("device")
 	cpuUsage := tests.IdleStateOfDevice()
 	fmt.Printf("Idle state of device: CPU %.2f%%\n", cpuUsage*100.0)
+	recorder.record("device", nil, []stepMetric{{"cpu_percent", cpuUsage * 100.0}})
 	contents := func(w *csv.Writer) {
 		w.Write([]string{"CPU (%)"})
 		cpu := fmt.Sprintf("%.4f%%", cpuUsage)
@@ -341,15 +1147,16 @@ func testIdleStateOfDevice(logfilePrefix string, logfilePostfix string) {
 // Idle state test of a process (legacy - by PID)
 func testIdleStateOfProcess(logfilePrefix string, logfilePostfix string, pid uint) {
 	filename := testResultsDirectory + logfilePrefix + "-idleStateOfProcess" + logfilePostfix + ".csv"
+	recorder.startStep(strconv.Itoa(int(pid)))
 	idleStateOfProcess := tests.IdleStateOfProcess(pid)
 	fmt.Printf("Idle state of \"%d\" process: CPU %.2f%%, RAM %dMB\n", pid, idleStateOfProcess.Cpu*100.0, idleStateOfProcess.Ram/1e6)
 	if idleStateOfProcess.Cpu == 0 && idleStateOfProcess.Ram == 0 {
-		str := fmt.Sprintf("\nI could not monitor a process with PID \"%d\" because it could not be found.\n\n", pid)
-		fmt.Printf(util.WarningColor, str)
+		slog.Warn(fmt.Sprintf("I could not monitor a process with PID \"%d\" because it could not be found.", pid))
 	}
 	if idleStateOfProcess == nil {
 		return
 	}
+	recorder.record(strconv.Itoa(int(pid)), idleStateOfProcess, []stepMetric{{"cpu_percent", idleStateOfProcess.Cpu * 100.0}, {"ram_mb", float64(idleStateOfProcess.Ram) / 1e6}})
 	contents := func(w *csv.Writer) {
 		w.Write([]string{"CPU (%)", "RAM (MB)"})
 		cpu := fmt.Sprintf("%.4f%%", idleStateOfProcess.Cpu)
@@ -371,9 +1178,9 @@ func testIdleStateOfProcesses(logfilePrefix string, logfilePostfix string, proce
 		if usage.ProcessCount > 0 {
 			fmt.Printf("Idle state of \"%s\" process(es): %d instances, CPU %.2f%%, RAM %dMB\n",
 				processName, usage.ProcessCount, usage.Cpu*100.0, usage.Ram/1e6)
+			recorder.record(processName, usage, []stepMetric{{"cpu_percent", usage.Cpu * 100.0}, {"ram_mb", float64(usage.Ram) / 1e6}})
 		} else {
-			fmt.Printf(util.WarningColor,
-				fmt.Sprintf("\nI could not monitor any processes with name \"%s\" because none were found.\n", processName))
+			slog.Warn(fmt.Sprintf("I could not monitor any processes with name \"%s\" because none were found.", processName))
 		}
 	}
 
@@ -393,27 +1200,37 @@ func testIdleStateOfProcesses(logfilePrefix string, logfilePostfix string, proce
 }
 
 // HTTP Burst test barrage
-func testHTTP_Burst(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, countTestsToRun int, fn model.Fn, pid uint, isHttps bool, processNames []string) {
-	serverProtocol := ""
-	testNameForFile := ""
+func testHTTP_Burst(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, countTestsToRun int, fn model.Fn, pid uint, isHttps bool, processNames []string, clientOptions util.HTTPClientOptions, compareOptions *util.HTTPClientOptions, repetitions uint, stabilityThreshold float64) {
+	serverProtocol := "http://"
 	if isHttps {
 		serverProtocol = "https://"
-		testNameForFile = "-httpsBurstTest"
-	} else {
-		serverProtocol = "http://"
-		testNameForFile = "-httpBurstTest"
 	}
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	testNameForFile := httpTestName(isHttps, clientOptions, "Burst")
 	url := fmt.Sprintf("%s%s:%d/download/100000", serverProtocol, serverHost, serverPort)
 	filename := testResultsDirectory + logfilePrefix + testNameForFile + logfilePostfix + ".csv"
+	var comparisons []policyComparison
 	contents := func(w *csv.Writer) {
 		// Generate dynamic headers based on process names
-		baseHeaders := []string{"number of http requests in burst", "time to complete (ms)", "failure rate (%)"}
+		baseHeaders := generateHTTPHeaders([]string{"number of http requests in burst", "time to complete (ms)", "failure rate (%)"})
 		headers := generateProcessHeaders(baseHeaders, processNames)
 		w.Write(headers)
 
-		for i := 0; i < countTestsToRun; i++ {
-			burstSize := fn(i)
-			result := tests.HttpBurstTest(url, burstSize, pid, isHttps, processNames)
+		// every step is repeated countRepetitions times in a row
+		for i := 0; i < countTestsToRun*countRepetitions; i++ {
+			if i%countRepetitions != 0 {
+				time.Sleep(repetitionRest)
+			}
+			burstSize := fn(i / countRepetitions)
+			recorder.startStep(strconv.Itoa(burstSize))
+			// the policy that runs first alternates between steps so that neither is favored by running second
+			var comparedResult model.BurstTest
+			compareFirst := compareOptions != nil && i%2 == 1
+			if compareFirst {
+				comparedResult = tests.HttpBurstTest(url, burstSize, pid, isHttps, processNames, *compareOptions)
+			}
+			result := tests.HttpBurstTest(url, burstSize, pid, isHttps, processNames, clientOptions)
 			failureRate := fmt.Sprintf("%.4f", result.FailureRate)
 
 			// Build row data with base values
@@ -422,256 +1239,596 @@ func testHTTP_Burst(logfilePrefix string, logfilePostfix string, serverHost stri
 				strconv.Itoa(int(result.Duration.Milliseconds())),
 				failureRate,
 			}
+			rowData = append(rowData, generateHTTPData(result.Latency, result.Protocols, result.Failures, result.CountFallbacks, result.FallbackCost, result.CountNewConnections, result.CountReusedConnections, result.ProxyPaths)...)
 
 			// Add process-specific data
 			processData := generateProcessData(result.ProcessCpuAndRam, processNames)
 			rowData = append(rowData, processData...)
 
 			if err := w.Write(rowData); err != nil {
-				log.Fatalln("error writing record to file", err)
+				fatal("error writing record to file", err)
 			}
 			w.Flush()
+			summary.add(strconv.Itoa(burstSize), burstMetrics(result))
+			recorder.record(strconv.Itoa(burstSize), result, burstMetrics(result))
+
+			if compareOptions != nil {
+				if !compareFirst {
+					comparedResult = tests.HttpBurstTest(url, burstSize, pid, isHttps, processNames, *compareOptions)
+				}
+				comparisons = append(comparisons, policyComparison{
+					step: burstSize,
+					a:    httpStep{result.Duration, result.FailureRate, result.Latency, result.CountNewConnections, result.CountReusedConnections},
+					b:    httpStep{comparedResult.Duration, comparedResult.FailureRate, comparedResult.Latency, comparedResult.CountNewConnections, comparedResult.CountReusedConnections},
+				})
+			}
 		}
 	}
 	createLogFile(filename, contents)
+	if compareOptions != nil {
+		writePolicyComparison(logfilePrefix+testNameForFile+"PolicyComparison"+logfilePostfix, "number of http requests in burst", clientOptions, *compareOptions, comparisons)
+	}
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+testNameForFile+"Summary"+logfilePostfix, "number of http requests in burst", stabilityThreshold)
+	}
+	fmt.Printf("\n")
+}
+
+// WebSocket echo test for each message size
+func testWebSocket(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, isSecure bool, messageSizes []uint, countMessages uint, testDuration time.Duration, proxy *util.ProxySelector, processNames []string, repetitions uint, stabilityThreshold float64) {
+	serverProtocol := "ws://"
+	testNameForFile := "-wsTest"
+	if isSecure {
+		serverProtocol = "wss://"
+		testNameForFile = "-wssTest"
+	}
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	url := fmt.Sprintf("%s%s/ws", serverProtocol, net.JoinHostPort(serverHost, strconv.Itoa(int(serverPort))))
+	filename := testResultsDirectory + logfilePrefix + testNameForFile + logfilePostfix + ".csv"
+	contents := func(w *csv.Writer) {
+		baseHeaders := []string{"message size (B)", "upgrade latency (ms)", "messages sent", "messages received", "max messages per second", "failure classes", "proxy path"}
+		headers := generateProcessHeaders(generateLatencyStatsHeaders(baseHeaders), processNames)
+		w.Write(headers)
+
+		// every message size is repeated countRepetitions times in a row
+		for i := 0; i < len(messageSizes)*countRepetitions; i++ {
+			if i%countRepetitions != 0 {
+				time.Sleep(repetitionRest)
+			}
+			messageSize := messageSizes[i/countRepetitions]
+			recorder.startStep(strconv.Itoa(int(messageSize)))
+			result, err := tests.WebSocketTest(url, messageSize, countMessages, testDuration, proxy, processNames)
+			if err != nil {
+				slog.Error("WebSocket test failed", "message_size", messageSize, "error", err)
+				continue
+			}
+			fmt.Printf("%dB: upgrade %.3fms, %s, %.0f messages/s\n", messageSize, durationToMilliseconds(result.UpgradeLatency), formatLatencyStats(result.Latency), result.MessagesPerSecond)
+			metrics := append(pingMetrics(result.Latency),
+				stepMetric{"upgrade_latency_ms", durationToMilliseconds(result.UpgradeLatency)},
+				stepMetric{"messages_per_second", result.MessagesPerSecond})
+			summary.add(strconv.Itoa(int(messageSize)), metrics)
+			recorder.record(strconv.Itoa(int(messageSize)), result, metrics)
+			rowData := []string{
+				strconv.Itoa(int(messageSize)),
+				fmt.Sprintf("%.3f", durationToMilliseconds(result.UpgradeLatency)),
+				strconv.Itoa(int(result.CountMessagesSent)),
+				strconv.Itoa(int(result.CountMessagesReceived)),
+				fmt.Sprintf("%.0f", result.MessagesPerSecond),
+				util.FormatCounts(result.Failures),
+				result.ProxyPath,
+			}
+			rowData = append(rowData, generateLatencyStatsData(result.Latency)...)
+			rowData = append(rowData, generateProcessData(result.ProcessCpuAndRam, processNames)...)
+			w.Write(rowData)
+			w.Flush()
+		}
+	}
+	createLogFile(filename, contents)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+testNameForFile+"Summary"+logfilePostfix, "message size (B)", stabilityThreshold)
+	}
+	fmt.Printf("\n")
+}
+
+// gRPC unary calls at each rate, server-streaming throughput and bidirectional streaming latency
+func testGRPC(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, isSecure bool, restDuration time.Duration, rates []int, testDuration time.Duration, messageSize uint, streamBytes uint64, countMessages uint, processNames []string, repetitions uint, stabilityThreshold float64) {
+	conn, err := tests.NewGrpcConn(serverHost, serverPort, isSecure)
+	if err != nil {
+		slog.Error("Skipping gRPC test", "error", err)
+		return
+	}
+	defer conn.Close()
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+
+	filename := testResultsDirectory + logfilePrefix + "-grpcUnaryRateTest" + logfilePostfix + ".csv"
+	createLogFile(filename, func(w *csv.Writer) {
+		baseHeaders := generateLatencyStatsHeaders([]string{"calls per second", "test duration (ms)", "message size (B)", "failure rate (%)", "failure classes"})
+		w.Write(generateProcessHeaders(baseHeaders, processNames))
+
+		// every rate is repeated countRepetitions times in a row
+		for i := 0; i < len(rates)*countRepetitions; i++ {
+			if i > 0 {
+				time.Sleep(restDuration)
+			}
+			requestsPerSecond := rates[i/countRepetitions]
+			recorder.startStep(fmt.Sprintf("unary %d", requestsPerSecond))
+			result := tests.GrpcUnaryRateTest(conn, testDuration, requestsPerSecond, messageSize, processNames)
+			fmt.Printf("%d calls/s: %s, failure rate %.4f\n", requestsPerSecond, formatLatencyStats(result.Latency), result.FailureRate)
+			summary.add(fmt.Sprintf("unary %d", requestsPerSecond), rateMetrics(result))
+			recorder.record(fmt.Sprintf("unary %d", requestsPerSecond), result, rateMetrics(result))
+			rowData := []string{
+				strconv.Itoa(requestsPerSecond),
+				strconv.Itoa(int(testDuration.Milliseconds())),
+				strconv.Itoa(int(messageSize)),
+				fmt.Sprintf("%.4f", result.FailureRate),
+				util.FormatCounts(result.Failures),
+			}
+			rowData = append(rowData, generateLatencyStatsData(result.Latency)...)
+			rowData = append(rowData, generateProcessData(result.ProcessCpuAndRam, processNames)...)
+			w.Write(rowData)
+			w.Flush()
+		}
+	})
+
+	filename = testResultsDirectory + logfilePrefix + "-grpcStreamThroughputTest" + logfilePostfix + ".csv"
+	createLogFile(filename, func(w *csv.Writer) {
+		baseHeaders := []string{"bytes transferred (MB)", "duration (ms)", "transfer rate (MB/s)", "transfer rate (Mb/s)"}
+		w.Write(generateProcessHeaders(baseHeaders, processNames))
+
+		for i := 0; i < countRepetitions; i++ {
+			if i > 0 {
+				time.Sleep(repetitionRest)
+			}
+			recorder.startStep("stream")
+			result, err := tests.GrpcServerStreamTest(conn, streamBytes, processNames)
+			if err != nil {
+				slog.Error("gRPC server streaming failed", "error", err)
+			}
+			Bps := 0.0
+			if result.DurationNanoseconds > 0 {
+				Bps = float64(result.CountBytesTransferred) / (float64(result.DurationNanoseconds) / 1e9)
+			}
+			bps := Bps * 8
+			fmt.Printf("gRPC stream\t--------- %.0fMB @ %.0fMB/s (%.0fMb/s) ------------\n", float64(result.CountBytesTransferred)/1e6, Bps/1e6, bps/1e6)
+			summary.add("stream", throughputMetrics(result))
+			recorder.record("stream", result, throughputMetrics(result))
+			rowData := []string{
+				fmt.Sprintf("%.0f", float64(result.CountBytesTransferred)/1e6),
+				fmt.Sprintf("%.0f", float64(result.DurationNanoseconds)/1e6),
+				fmt.Sprintf("%.0f", Bps/1e6),
+				fmt.Sprintf("%.0f", bps/1e6),
+			}
+			w.Write(append(rowData, generateProcessData(result.ProcessCpuAndRam, processNames)...))
+			w.Flush()
+		}
+	})
+
+	labelHeaders := append([]string{"message size (B)", "failure classes"}, repetitionLabelHeader(countRepetitions)...)
+	var probes []latencyProbe
+	for i := 0; i < countRepetitions; i++ {
+		if i > 0 {
+			time.Sleep(repetitionRest)
+		}
+		recorder.startStep("bidi")
+		result, failures := tests.GrpcBidiLatencyTest(conn, countMessages, messageSize)
+		fmt.Printf("gRPC bidi stream: %s\n", formatLatencyStats(result.Stats))
+		summary.add("bidi", pingMetrics(result.Stats))
+		recorder.record("bidi", result, pingMetrics(result.Stats))
+		labels := []string{strconv.Itoa(int(messageSize)), util.FormatCounts(failures)}
+		probes = append(probes, latencyProbe{labels: append(labels, repetitionLabel(countRepetitions, i)...), result: result})
+	}
+	writeLatencyResults(logfilePrefix+"-grpcBidiTest"+logfilePostfix, labelHeaders, probes)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+"-grpcTestSummary"+logfilePostfix, "step", stabilityThreshold)
+	}
 	fmt.Printf("\n")
 }
 
 // HTTP Rate test barrage
-func testHTTP_Rate(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, restDuration time.Duration, countTestsToRun int, fn model.Fn, testDuration time.Duration, pid uint, isHttps bool) {
-	serverProtocol := ""
-	testNameForFile := ""
+func testHTTP_Rate(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, restDuration time.Duration, countTestsToRun int, fn model.Fn, testDuration time.Duration, pid uint, isHttps bool, clientOptions util.HTTPClientOptions, compareOptions *util.HTTPClientOptions, repetitions uint, stabilityThreshold float64) {
+	serverProtocol := "http://"
 	if isHttps {
 		serverProtocol = "https://"
-		testNameForFile = "-httpsRateTest"
-	} else {
-		serverProtocol = "http://"
-		testNameForFile = "-httpRateTest"
 	}
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	testNameForFile := httpTestName(isHttps, clientOptions, "Rate")
 	url := fmt.Sprintf("%s%s:%d/download/1000", serverProtocol, serverHost, serverPort)
 	filename := testResultsDirectory + logfilePrefix + testNameForFile + logfilePostfix + ".csv"
+	var comparisons []policyComparison
 	contents := func(w *csv.Writer) {
-		w.Write([]string{"requests per second", "test duration (ms)", "failure rate (%)", "average CPU (%)", "average RAM (MB)"})
+		w.Write(append(generateHTTPHeaders([]string{"requests per second", "test duration (ms)", "failure rate (%)"}), "average CPU (%)", "average RAM (MB)"))
 
-		for i := 0; i < countTestsToRun; i++ {
-			requestsPerSecond := fn(i)
-			result := tests.HttpRateTest(url, testDuration, requestsPerSecond, pid, isHttps, nil)
+		// every step is repeated countRepetitions times in a row
+		for i := 0; i < countTestsToRun*countRepetitions; i++ {
+			if i%countRepetitions != 0 {
+				time.Sleep(restDuration)
+			}
+			requestsPerSecond := fn(i / countRepetitions)
+			recorder.startStep(strconv.Itoa(requestsPerSecond))
+			// the policy that runs first alternates between steps so that neither is favored by running second
+			var comparedResult model.RateTest
+			compareFirst := compareOptions != nil && i%2 == 1
+			if compareFirst {
+				comparedResult = tests.HttpRateTest(url, testDuration, requestsPerSecond, pid, isHttps, nil, *compareOptions)
+				time.Sleep(restDuration)
+			}
+			result := tests.HttpRateTest(url, testDuration, requestsPerSecond, pid, isHttps, nil, clientOptions)
 			var cpu, ram string
 			if result.CpuAndRam.Ram != 0 {
 				cpu = fmt.Sprintf("%.4f", result.CpuAndRam.Cpu)
 				ram = fmt.Sprintf("%d", result.CpuAndRam.Ram/1e6)
 			}
 			failureRate := fmt.Sprintf("%.4f", result.FailureRate)
-			w.Write([]string{strconv.Itoa(requestsPerSecond), strconv.Itoa(int(testDuration.Milliseconds())), failureRate, cpu, ram})
+			rowData := []string{strconv.Itoa(requestsPerSecond), strconv.Itoa(int(testDuration.Milliseconds())), failureRate}
+			rowData = append(rowData, generateHTTPData(result.Latency, result.Protocols, result.Failures, result.CountFallbacks, result.FallbackCost, result.CountNewConnections, result.CountReusedConnections, result.ProxyPaths)...)
+			w.Write(append(rowData, cpu, ram))
 			w.Flush()
+			summary.add(strconv.Itoa(requestsPerSecond), rateMetrics(result))
+			recorder.record(strconv.Itoa(requestsPerSecond), result, rateMetrics(result))
+
+			if compareOptions != nil {
+				if !compareFirst {
+					time.Sleep(restDuration)
+					comparedResult = tests.HttpRateTest(url, testDuration, requestsPerSecond, pid, isHttps, nil, *compareOptions)
+				}
+				comparisons = append(comparisons, policyComparison{
+					step: requestsPerSecond,
+					a:    httpStep{0, result.FailureRate, result.Latency, result.CountNewConnections, result.CountReusedConnections},
+					b:    httpStep{0, comparedResult.FailureRate, comparedResult.Latency, comparedResult.CountNewConnections, comparedResult.CountReusedConnections},
+				})
+			}
 		}
 	}
 	createLogFile(filename, contents)
+	if compareOptions != nil {
+		writePolicyComparison(logfilePrefix+testNameForFile+"PolicyComparison"+logfilePostfix, "requests per second", clientOptions, *compareOptions, comparisons)
+	}
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+testNameForFile+"Summary"+logfilePostfix, "requests per second", stabilityThreshold)
+	}
 	fmt.Printf("\n")
 }
 
-func testHTTP_Throughput(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, pid uint, isHttps bool, processNames []string) {
-	serverProtocol := ""
-	testNameForFile := ""
+func testHTTP_Throughput(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, pid uint, isHttps bool, processNames []string, clientOptions util.HTTPClientOptions, repetitions uint, stabilityThreshold float64) {
+	serverProtocol := "http://"
 	if isHttps {
 		serverProtocol = "https://"
-		testNameForFile = "-httpsThroughputTest"
-	} else {
-		serverProtocol = "http://"
-		testNameForFile = "-httpThroughputTest"
 	}
-	fmt.Printf("Half Duplex Throughput:\n")
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	testNameForFile := httpTestName(isHttps, clientOptions, "Throughput")
 	filename := testResultsDirectory + logfilePrefix + testNameForFile + logfilePostfix + ".csv"
 	contents := func(w *csv.Writer) {
 		// Generate dynamic headers based on process names
-		baseHeaders := []string{"transfer mode (half/full duplex)", "bytes transferred (MB)", "duration (ms)", "transfer rate (MB/s)", "transfer rate (Mb/s)"}
+		baseHeaders := []string{"transfer mode (half/full duplex)", "bytes transferred (MB)", "duration (ms)", "transfer rate (MB/s)", "transfer rate (Mb/s)", "protocol", "QUIC fallbacks", "QUIC fallback cost (ms)", "proxy paths"}
 		headers := generateProcessHeaders(baseHeaders, processNames)
 		w.Write(headers)
 
-		uploadThroughputTestResult, _ := tests.UploadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames)
-		Bps := float64(uploadThroughputTestResult.CountBytesTransferred) / (float64(uploadThroughputTestResult.DurationNanoseconds) / 1e9)
-		bps := Bps * 8
-		fmt.Printf("%s\t--------- %.0fMB @ %.0fMB/s (%.0fMb/s) ------------\n", uploadThroughputTestResult.Type, float64(uploadThroughputTestResult.CountBytesTransferred)/1e6, Bps/1e6, bps/1e6)
-
-		// Build row data with base values
-		rowData := []string{
-			fmt.Sprintf("%s", uploadThroughputTestResult.Type),
-			fmt.Sprintf("%.0f", float64(uploadThroughputTestResult.CountBytesTransferred)/1e6),
-			fmt.Sprintf("%.0f", (float64(uploadThroughputTestResult.DurationNanoseconds) / 1e6)),
-			fmt.Sprintf("%.0f", Bps/1e6),
-			fmt.Sprintf("%.0f", bps/1e6),
-		}
-
-		// Add process-specific data
-		processData := generateProcessData(uploadThroughputTestResult.ProcessCpuAndRam, processNames)
-		rowData = append(rowData, processData...)
-		w.Write(rowData)
-		w.Flush()
-
-		downloadThroughputTestResult, _ := tests.DownloadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames)
-		Bps = float64(downloadThroughputTestResult.CountBytesTransferred) / (float64(downloadThroughputTestResult.DurationNanoseconds) / 1e9)
-		bps = Bps * 8
-		fmt.Printf("%s\t--------- %.0fMB @ %.0fMB/s (%.0fMb/s) ------------\n", downloadThroughputTestResult.Type, float64(downloadThroughputTestResult.CountBytesTransferred)/1e6, Bps/1e6, bps/1e6)
-
-		// Build row data with base values for download
-		rowData = []string{
-			fmt.Sprintf("%s", downloadThroughputTestResult.Type),
-			fmt.Sprintf("%.0f", float64(downloadThroughputTestResult.CountBytesTransferred)/1e6),
-			fmt.Sprintf("%.0f", (float64(downloadThroughputTestResult.DurationNanoseconds) / 1e6)),
-			fmt.Sprintf("%.0f", Bps/1e6),
-			fmt.Sprintf("%.0f", bps/1e6),
-		}
-
-		// Add process-specific data for download
-		processData = generateProcessData(downloadThroughputTestResult.ProcessCpuAndRam, processNames)
-		rowData = append(rowData, processData...)
-		w.Write(rowData)
-		w.Flush()
-
-		fmt.Printf("\n")
-
-		fmt.Printf("Full Duplex Throughput:\n")
-		results := make(chan model.ThroughputTest, 2)
-		errors := make(chan error, 2)
-		var wg sync.WaitGroup
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			result, err := tests.DownloadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames)
-			result.Type = model.RX_FullDuplex
-			if err != nil {
-				errors <- err
-				return
-			}
-			results <- result
-		}()
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			result, err := tests.UploadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames)
-			result.Type = model.TX_FullDuplex
-			if err != nil {
-				errors <- err
-				return
+		// every repetition runs the half duplex and then the full duplex transfers
+		for repetition := 0; repetition < countRepetitions; repetition++ {
+			if repetition > 0 {
+				time.Sleep(repetitionRest)
 			}
-			results <- result
-		}()
-
-		go func() {
-			wg.Wait()
-			close(results)
-			close(errors)
-		}()
-		for err := range errors {
-			fmt.Printf("%s\n", err.Error())
-			return
+			writeThroughputRepetition(w, serverProtocol, serverHost, serverPort, pid, processNames, clientOptions, summary)
+		}
+	}
+	createLogFile(filename, contents)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+testNameForFile+"Summary"+logfilePostfix, "transfer mode (half/full duplex)", stabilityThreshold)
+	}
+	fmt.Printf("\n")
+}
 
+// writeThroughputRepetition runs the half duplex and full duplex transfers of a throughput test once and writes their results
+func writeThroughputRepetition(w *csv.Writer, serverProtocol string, serverHost string, serverPort uint, pid uint, processNames []string, clientOptions util.HTTPClientOptions, summary *repetitionSummary) {
+	fmt.Printf("Half Duplex Throughput:\n")
+	recorder.startStep(model.TX.String())
+	uploadThroughputTestResult, err := tests.UploadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames, clientOptions)
+	if err != nil {
+		slog.Error("Upload throughput test failed", "error", err)
+	} else {
+		writeThroughputResult(w, uploadThroughputTestResult, processNames, summary)
+	}
+	recorder.startStep(model.ThroughputType(model.RX).String())
+	downloadThroughputTestResult, err := tests.DownloadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames, clientOptions)
+	if err != nil {
+		slog.Error("Download throughput test failed", "error", err)
+	} else {
+		writeThroughputResult(w, downloadThroughputTestResult, processNames, summary)
+	}
+
+	fmt.Printf("\n")
+
+	fmt.Printf("Full Duplex Throughput:\n")
+	// both directions run at once, the step in progress is shown as the first of them
+	recorder.startStep(model.ThroughputType(model.RX_FullDuplex).String())
+	results := make(chan model.ThroughputTest, 2)
+	errors := make(chan error, 2)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result, err := tests.DownloadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames, clientOptions)
+		result.Type = model.RX_FullDuplex
+		if err != nil {
+			errors <- err
+			return
 		}
+		results <- result
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result, err := tests.UploadThroughputTest(serverProtocol, serverHost, serverPort, pid, processNames, clientOptions)
+		result.Type = model.TX_FullDuplex
+		if err != nil {
+			errors <- err
+			return
+		}
+		results <- result
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+		close(errors)
+	}()
+	// a failed direction does not lose the result of the other
+	for err := range errors {
+		slog.Error("Throughput test failed", "error", err)
+	}
+	for throughputTestResult := range results {
+		writeThroughputResult(w, throughputTestResult, processNames, summary)
+	}
+}
 
-		for throughputTestResult := range results {
-			Bps := float64(throughputTestResult.CountBytesTransferred) / (float64(throughputTestResult.DurationNanoseconds) / 1e9)
-			bps := Bps * 8
-			fmt.Printf("%s\t--------- %.0fMB @ %.0fMB/s (%.0fMb/s) ------------\n", throughputTestResult.Type, float64(throughputTestResult.CountBytesTransferred)/1e6, Bps/1e6, bps/1e6)
+// writeThroughputResult prints, writes and records the result of a transfer of a throughput test
+func writeThroughputResult(w *csv.Writer, result model.ThroughputTest, processNames []string, summary *repetitionSummary) {
+	Bps := float64(result.CountBytesTransferred) / (float64(result.DurationNanoseconds) / 1e9)
+	bps := Bps * 8
+	fmt.Printf("%s\t--------- %.0fMB @ %.0fMB/s (%.0fMb/s) ------------\n", result.Type, float64(result.CountBytesTransferred)/1e6, Bps/1e6, bps/1e6)
+
+	// Build row data with base values
+	rowData := []string{
+		fmt.Sprintf("%s", result.Type),
+		fmt.Sprintf("%.0f", float64(result.CountBytesTransferred)/1e6),
+		fmt.Sprintf("%.0f", (float64(result.DurationNanoseconds) / 1e6)),
+		fmt.Sprintf("%.0f", Bps/1e6),
+		fmt.Sprintf("%.0f", bps/1e6),
+		result.Protocol,
+		strconv.Itoa(int(result.CountFallbacks)),
+		fmt.Sprintf("%.3f", durationToMilliseconds(result.FallbackCost)),
+		util.FormatCounts(result.ProxyPaths),
+	}
 
-			// Build row data with base values for full duplex
-			rowData = []string{
-				fmt.Sprintf("%s", throughputTestResult.Type),
-				fmt.Sprintf("%.0f", float64(throughputTestResult.CountBytesTransferred)/1e6),
-				fmt.Sprintf("%.0f", (float64(throughputTestResult.DurationNanoseconds) / 1e6)),
-				fmt.Sprintf("%.0f", Bps/1e6),
-				fmt.Sprintf("%.0f", bps/1e6),
-			}
+	// Add process-specific data
+	processData := generateProcessData(result.ProcessCpuAndRam, processNames)
+	rowData = append(rowData, processData...)
+	w.Write(rowData)
+	w.Flush()
+	summary.add(result.Type.String(), throughputMetrics(result))
+	recorder.record(result.Type.String(), result, throughputMetrics(result))
+}
 
-			// Add process-specific data for full duplex
-			processData = generateProcessData(throughputTestResult.ProcessCpuAndRam, processNames)
-			rowData = append(rowData, processData...)
-			w.Write(rowData)
-			w.Flush()
+func testPing(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, localAddress string, countSamples uint, repetitions uint, stabilityThreshold float64) {
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	var probes []latencyProbe
+	for i := 0; i < countRepetitions; i++ {
+		if i > 0 {
+			time.Sleep(repetitionRest)
+		}
+		recorder.startStep(strconv.Itoa(int(countSamples)))
+		result, ok := udpPingTest(serverHost, serverPort, localAddress, countSamples)
+		if !ok {
+			continue
 		}
+		fmt.Printf("Ping: %s\n", formatLatencyStats(result.Stats))
+		summary.add(strconv.Itoa(int(countSamples)), pingMetrics(result.Stats))
+		recorder.record(strconv.Itoa(int(countSamples)), result, pingMetrics(result.Stats))
+		probes = append(probes, latencyProbe{labels: repetitionLabel(countRepetitions, i), result: result})
+	}
+	if len(probes) == 0 {
+		return
+	}
+	writeLatencyResults(logfilePrefix+"-pingTest"+logfilePostfix, repetitionLabelHeader(countRepetitions), probes)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+"-pingTestSummary"+logfilePostfix, "number of samples", stabilityThreshold)
 	}
-	createLogFile(filename, contents)
 	fmt.Printf("\n")
 }
 
-func testPing(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, countSamples uint) {
-	filename := testResultsDirectory + logfilePrefix + "-pingTest" + logfilePostfix + ".csv"
-	contents := func(w *csv.Writer) {
-		w.Write([]string{"ping average (ms)"})
-		address := fmt.Sprintf("%s:%d", serverHost, serverPort)
-		conn, err := net.Dial("udp", address)
-		if err != nil {
-			fmt.Printf("Some error %v", err)
-			return
+func testJitter(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, localAddress string, countDifferences uint, repetitions uint, stabilityThreshold float64) {
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	var probes []latencyProbe
+	for i := 0; i < countRepetitions; i++ {
+		if i > 0 {
+			time.Sleep(repetitionRest)
 		}
-		defer conn.Close()
-		averagePingMicroSeconds := 0.0
-		for i := uint(0); i < countSamples; i++ {
-			dt := tests.Ping(conn)
-			averagePingMicroSeconds += float64(dt.Microseconds())
-			// fmt.Printf("Ping: %.3fms\n", float64(dt.Microseconds())/1000.0)
+		recorder.startStep(strconv.Itoa(int(countDifferences)))
+		// n differences need n+1 consecutive samples
+		result, ok := udpPingTest(serverHost, serverPort, localAddress, countDifferences+1)
+		if !ok {
+			continue
 		}
-		averagePingMicroSeconds /= float64(countSamples)
-		fmt.Printf("Average Ping: %.3fms\n", averagePingMicroSeconds/1000.0)
-		averagePingMicroSecondsString := fmt.Sprintf("%.3f", averagePingMicroSeconds/1000.0)
-		w.Write([]string{averagePingMicroSecondsString})
-		w.Flush()
+		fmt.Printf("Jitter (RFC 3550): %.3fms, mean IPDV: %.3fms, max IPDV: %.3fms\n", durationToMilliseconds(result.Stats.Jitter), durationToMilliseconds(result.Stats.IPDV_Mean), durationToMilliseconds(result.Stats.IPDV_Max))
+		metrics := append(pingMetrics(result.Stats),
+			stepMetric{"ipdv_mean_ms", durationToMilliseconds(result.Stats.IPDV_Mean)},
+			stepMetric{"ipdv_max_ms", durationToMilliseconds(result.Stats.IPDV_Max)})
+		summary.add(strconv.Itoa(int(countDifferences)), metrics)
+		recorder.record(strconv.Itoa(int(countDifferences)), result, metrics)
+		probes = append(probes, latencyProbe{labels: repetitionLabel(countRepetitions, i), result: result})
+	}
+	if len(probes) == 0 {
+		return
+	}
+	writeLatencyResults(logfilePrefix+"-jitterTest"+logfilePostfix, repetitionLabelHeader(countRepetitions), probes)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+"-jitterTestSummary"+logfilePostfix, "number of differences", stabilityThreshold)
 	}
-	createLogFile(filename, contents)
 	fmt.Printf("\n")
 }
 
-func testJitter(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, countDifferences uint) {
-	filename := testResultsDirectory + logfilePrefix + "-jitterTest" + logfilePostfix + ".csv"
-	contents := func(w *csv.Writer) {
-		w.Write([]string{"average jitter (ms)"})
-		address := fmt.Sprintf("%s:%d", serverHost, serverPort)
-		conn, err := net.Dial("udp", address)
+// udpPingTest pings the UDP echo server at serverHost:serverPort countSamples times over a new connection, so that late
+// echoes of an earlier run are not taken for those of this one
+func udpPingTest(serverHost string, serverPort uint, localAddress string, countSamples uint) (model.PingTest, bool) {
+	address := net.JoinHostPort(serverHost, strconv.Itoa(int(serverPort)))
+	conn, err := util.LocalDialer("udp", localAddress, 0).Dial("udp", address)
+	if err != nil {
+		slog.Error("Could not dial", "address", address, "error", err)
+		return model.PingTest{}, false
+	}
+	defer conn.Close()
+	return tests.PingTest(conn, countSamples), true
+}
+
+// repetitionLabelHeader returns the header of the label column numbering the repetitions of a latency test, none if it runs once
+func repetitionLabelHeader(countRepetitions int) []string {
+	if countRepetitions > 1 {
+		return []string{"repetition"}
+	}
+	return nil
+}
+
+// repetitionLabel returns the label of repetition i of a latency test, none if it runs once
+func repetitionLabel(countRepetitions int, i int) []string {
+	if countRepetitions > 1 {
+		return []string{strconv.Itoa(i + 1)}
+	}
+	return nil
+}
+
+func testICMP_Ping(logfilePrefix string, logfilePostfix string, serverHost string, countSamples uint, repetitions uint, stabilityThreshold float64) {
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	var probes []latencyProbe
+	for i := 0; i < countRepetitions; i++ {
+		if i > 0 {
+			time.Sleep(repetitionRest)
+		}
+		recorder.startStep(strconv.Itoa(int(countSamples)))
+		result, err := tests.IcmpPingTest(serverHost, countSamples)
 		if err != nil {
-			fmt.Printf("Some error %v", err)
-			return
+			slog.Error("ICMP ping failed", "error", err)
+			continue
+		}
+		fmt.Printf("ICMP Ping: %s\n", formatLatencyStats(result.Stats))
+		summary.add(strconv.Itoa(int(countSamples)), pingMetrics(result.Stats))
+		recorder.record(strconv.Itoa(int(countSamples)), result, pingMetrics(result.Stats))
+		probes = append(probes, latencyProbe{labels: repetitionLabel(countRepetitions, i), result: result})
+	}
+	if len(probes) == 0 {
+		return
+	}
+	writeLatencyResults(logfilePrefix+"-icmpPingTest"+logfilePostfix, repetitionLabelHeader(countRepetitions), probes)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+"-icmpPingTestSummary"+logfilePostfix, "number of samples", stabilityThreshold)
+	}
+	fmt.Printf("\n")
+}
+
+// TCP ping test, timing connections to each port and echoes over a persistent connection to the echo port
+func testTCP_Ping(logfilePrefix string, logfilePostfix string, serverHost string, ports []uint, echoPort uint, countSamples uint, repetitions uint, stabilityThreshold float64) {
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	var probes []latencyProbe
+	// every port is timed countRepetitions times in a row
+	for i := 0; i < len(ports)*countRepetitions; i++ {
+		if i%countRepetitions != 0 {
+			time.Sleep(repetitionRest)
 		}
-		defer conn.Close()
-		averagePingMicroSeconds := 0.0
-		dt1 := tests.Ping(conn)
-		dt2 := time.Second
-		for i := uint(0); i < countDifferences; i++ {
-			dt2 = tests.Ping(conn)
-			dtDiffMicroseconds := dt1.Microseconds() - dt2.Microseconds()
-			if dtDiffMicroseconds < 0 {
-				dtDiffMicroseconds *= -1
+		port := ports[i/countRepetitions]
+		address := net.JoinHostPort(serverHost, strconv.Itoa(int(port)))
+		step := fmt.Sprintf("connect %d", port)
+		recorder.startStep(step)
+		result := tests.TcpConnectPingTest(address, countSamples)
+		fmt.Printf("TCP connect to port %d: %s\n", port, formatLatencyStats(result.Stats))
+		summary.add(step, pingMetrics(result.Stats))
+		recorder.record(step, result, pingMetrics(result.Stats))
+		labels := []string{"connect", strconv.Itoa(int(port))}
+		probes = append(probes, latencyProbe{labels: append(labels, repetitionLabel(countRepetitions, i%countRepetitions)...), result: result})
+	}
+	if echoPort > 0 {
+		address := net.JoinHostPort(serverHost, strconv.Itoa(int(echoPort)))
+		step := fmt.Sprintf("echo %d", echoPort)
+		for i := 0; i < countRepetitions; i++ {
+			if i > 0 {
+				time.Sleep(repetitionRest)
+			}
+			recorder.startStep(step)
+			result, err := tests.TcpEchoPingTest(address, countSamples)
+			if err != nil {
+				slog.Error("TCP echo failed", "address", address, "error", err)
+				continue
 			}
-			averagePingMicroSeconds += float64(dtDiffMicroseconds)
-			dt1 = tests.Ping(conn)
+			fmt.Printf("TCP echo on port %d: %s\n", echoPort, formatLatencyStats(result.Stats))
+			summary.add(step, pingMetrics(result.Stats))
+			recorder.record(step, result, pingMetrics(result.Stats))
+			labels := []string{"echo", strconv.Itoa(int(echoPort))}
+			probes = append(probes, latencyProbe{labels: append(labels, repetitionLabel(countRepetitions, i)...), result: result})
 		}
-		averagePingMicroSeconds /= float64(countDifferences)
-		fmt.Printf("Average Jitter: %.3fms\n", averagePingMicroSeconds/1000.0)
-		averagePingMicroSecondsString := fmt.Sprintf("%.3f", averagePingMicroSeconds/1000.0)
-		w.Write([]string{averagePingMicroSecondsString})
-		w.Flush()
 	}
-	createLogFile(filename, contents)
+	if len(probes) > 0 {
+		writeLatencyResults(logfilePrefix+"-tcpPingTest"+logfilePostfix, append([]string{"probe", "port"}, repetitionLabelHeader(countRepetitions)...), probes)
+	}
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+"-tcpPingTestSummary"+logfilePostfix, "probe", stabilityThreshold)
+	}
 	fmt.Printf("\n")
 }
 
-func testDNS_Burst(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, restDuration time.Duration, countTestsToRun int, fn model.Fn, pid uint, transportProtocol string, processNames []string) {
-	url := fmt.Sprintf("test.service")
-	testNameForFile := ""
-	switch transportProtocol {
-	case "udp":
-		testNameForFile = "-dnsUdpBurstTest"
-	case "tcp":
-		testNameForFile = "-dnsTcpBurstTest"
+// latencyProbe is the result of a ping test and the values of the label columns identifying it
+type latencyProbe struct {
+	labels []string
+	result model.PingTest
+}
+
+// writeLatencyResults writes the statistics of each probe to <name>.csv and the raw samples to <name>Samples.csv
+func writeLatencyResults(name string, labelHeaders []string, probes []latencyProbe) {
+	createLogFile(testResultsDirectory+name+".csv", func(w *csv.Writer) {
+		w.Write(generateLatencyStatsHeaders(labelHeaders))
+		for _, probe := range probes {
+			w.Write(append(append([]string{}, probe.labels...), generateLatencyStatsData(probe.result.Stats)...))
+		}
+	})
+	createLogFile(testResultsDirectory+name+"Samples.csv", func(w *csv.Writer) {
+		w.Write(append(append([]string{}, labelHeaders...), "sequence", "RTT (ms)", "lost"))
+		for _, probe := range probes {
+			for _, sample := range probe.result.Samples {
+				rtt := ""
+				if !sample.Lost {
+					rtt = fmt.Sprintf("%.3f", durationToMilliseconds(sample.RTT))
+				}
+				w.Write(append(append([]string{}, probe.labels...), strconv.Itoa(int(sample.Sequence)), rtt, strconv.FormatBool(sample.Lost)))
+			}
+		}
+	})
+}
+
+func testDNS_Burst(logfilePrefix string, logfilePostfix string, transport *tests.DnsTransport, names *tests.DnsNameSource, queryTypes []uint16, restDuration time.Duration, countTestsToRun int, fn model.Fn, pid uint, processNames []string, repetitions uint, stabilityThreshold float64) {
+	if transport == nil || names == nil {
+		return
 	}
+	defer transport.Close()
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	testNameForFile := dnsTestName(transport.Name, "Burst")
 	filename := testResultsDirectory + logfilePrefix + testNameForFile + logfilePostfix + ".csv"
 	contents := func(w *csv.Writer) {
 		// Generate dynamic headers based on process names
-		baseHeaders := []string{"number of requests in burst", "time to complete (ms)", "failure rate (%)"}
+		baseHeaders := generateDnsLatencyHeaders([]string{"number of requests in burst", "time to complete (ms)", "failure rate (%)"})
 		headers := generateProcessHeaders(baseHeaders, processNames)
 		w.Write(headers)
 
-		for i := 0; i < countTestsToRun; i++ {
-			burstSize := fn(i)
-			result := tests.DnsBurstTest(url, burstSize, pid, serverHost, serverPort, transportProtocol, processNames)
+		// every step is repeated countRepetitions times in a row
+		for i := 0; i < countTestsToRun*countRepetitions; i++ {
+			burstSize := fn(i / countRepetitions)
+			recorder.startStep(strconv.Itoa(burstSize))
+			result := tests.DnsBurstTest(names, queryTypes, burstSize, pid, transport, processNames)
 			failureRate := fmt.Sprintf("%.4f", result.FailureRate)
 
 			// Build row data with base values
@@ -680,41 +1837,48 @@ func testDNS_Burst(logfilePrefix string, logfilePostfix string, serverHost strin
 				strconv.Itoa(int(result.Duration.Milliseconds())),
 				failureRate,
 			}
+			rowData = append(rowData, generateDnsLatencyData(result.Latency, result.CachedLatency, result.UncachedLatency, result.ProxyPaths)...)
 
 			// Add process-specific data
 			processData := generateProcessData(result.ProcessCpuAndRam, processNames)
 			rowData = append(rowData, processData...)
 
 			if err := w.Write(rowData); err != nil {
-				log.Fatalln("error writing record to file", err)
+				fatal("error writing record to file", err)
 			}
 			w.Flush()
+			summary.add(strconv.Itoa(burstSize), burstMetrics(result))
+			recorder.record(strconv.Itoa(burstSize), result, burstMetrics(result))
 			time.Sleep(restDuration)
 		}
 	}
 	createLogFile(filename, contents)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+testNameForFile+"Summary"+logfilePostfix, "number of requests in burst", stabilityThreshold)
+	}
 	fmt.Printf("\n")
 }
 
-func testDNS_Rate(logfilePrefix string, logfilePostfix string, serverHost string, serverPort uint, restDuration time.Duration, countTestsToRun int, fn model.Fn, testDuration time.Duration, pid uint, transportProtocol string, processNames []string) {
-	url := fmt.Sprintf("test.service")
-	testNameForFile := ""
-	switch transportProtocol {
-	case "udp":
-		testNameForFile = "-dnsUdpRateTest"
-	case "tcp":
-		testNameForFile = "-dnsTcpRateTest"
+func testDNS_Rate(logfilePrefix string, logfilePostfix string, transport *tests.DnsTransport, names *tests.DnsNameSource, queryTypes []uint16, restDuration time.Duration, countTestsToRun int, fn model.Fn, testDuration time.Duration, pid uint, processNames []string, repetitions uint, stabilityThreshold float64) {
+	if transport == nil || names == nil {
+		return
 	}
+	defer transport.Close()
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	testNameForFile := dnsTestName(transport.Name, "Rate")
 	filename := testResultsDirectory + logfilePrefix + testNameForFile + logfilePostfix + ".csv"
 	contents := func(w *csv.Writer) {
 		// Generate dynamic headers based on process names
-		baseHeaders := []string{"requests per second", "test duration (ms)", "failure rate (%)"}
+		baseHeaders := generateDnsLatencyHeaders([]string{"requests per second", "test duration (ms)", "failure rate (%)"})
 		headers := generateProcessHeaders(baseHeaders, processNames)
 		w.Write(headers)
 
-		for i := 0; i < countTestsToRun; i++ {
-			requestsPerSecond := fn(i)
-			result := tests.DnsRateTest(url, testDuration, requestsPerSecond, pid, serverHost, serverPort, transportProtocol, processNames)
+		// every step is repeated countRepetitions times in a row
+		for i := 0; i < countTestsToRun*countRepetitions; i++ {
+			requestsPerSecond := fn(i / countRepetitions)
+			recorder.startStep(strconv.Itoa(requestsPerSecond))
+			result := tests.DnsRateTest(names, queryTypes, testDuration, requestsPerSecond, pid, transport, processNames)
 			failureRate := fmt.Sprintf("%.4f", result.FailureRate)
 
 			// Build row data with base values
@@ -723,6 +1887,7 @@ func testDNS_Rate(logfilePrefix string, logfilePostfix string, serverHost string
 				strconv.Itoa(int(testDuration.Milliseconds())),
 				failureRate,
 			}
+			rowData = append(rowData, generateDnsLatencyData(result.Latency, result.CachedLatency, result.UncachedLatency, result.ProxyPaths)...)
 
 			// Add process-specific data
 			processData := generateProcessData(result.ProcessCpuAndRam, processNames)
@@ -730,9 +1895,70 @@ func testDNS_Rate(logfilePrefix string, logfilePostfix string, serverHost string
 
 			w.Write(rowData)
 			w.Flush()
+			summary.add(strconv.Itoa(requestsPerSecond), rateMetrics(result))
+			recorder.record(strconv.Itoa(requestsPerSecond), result, rateMetrics(result))
 			time.Sleep(restDuration)
 		}
 	}
 	createLogFile(filename, contents)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+testNameForFile+"Summary"+logfilePostfix, "requests per second", stabilityThreshold)
+	}
+	fmt.Printf("\n")
+}
+
+// DNS matrix test, latency and failures for every combination of record type and response size
+func testDNS_Matrix(logfilePrefix string, logfilePostfix string, transport *tests.DnsTransport, queryName string, queryTypes []uint16, responseSizes []uint, countQueries uint, ednsBufferSize uint16, repetitions uint, stabilityThreshold float64) {
+	if transport == nil {
+		return
+	}
+	defer transport.Close()
+	countRepetitions := max(int(repetitions), 1)
+	summary := newRepetitionSummary()
+	filename := testResultsDirectory + logfilePrefix + "-dnsMatrixTest" + logfilePostfix + ".csv"
+	contents := func(w *csv.Writer) {
+		baseHeaders := append([]string{"transport", "query type", "requested response size (B)"}, repetitionLabelHeader(countRepetitions)...)
+		baseHeaders = append(baseHeaders, "largest response received (B)", "failure rate (%)", "truncated responses", "TCP fallbacks", "failure classes")
+		w.Write(generateLatencyStatsHeaders(baseHeaders))
+
+		for _, queryType := range queryTypes {
+			for _, responseSize := range responseSizes {
+				// every combination is repeated countRepetitions times in a row
+				for i := 0; i < countRepetitions; i++ {
+					if i > 0 {
+						time.Sleep(repetitionRest)
+					}
+					step := fmt.Sprintf("%s %dB", dns.TypeToString[queryType], responseSize)
+					recorder.startStep(step)
+					result := tests.DnsMatrixTest(queryName, queryType, responseSize, countQueries, ednsBufferSize, transport)
+					fmt.Printf("%s %dB: %s, %d truncated, %d TCP fallbacks\n", result.QueryType, responseSize, formatLatencyStats(result.Latency), result.CountTruncated, result.CountTcpFallbacks)
+					metrics := append(latencyMetrics(result.Latency),
+						stepMetric{"failure_rate", result.Latency.LossRate()},
+						stepMetric{"truncated_responses", float64(result.CountTruncated)})
+					summary.add(step, metrics)
+					recorder.record(step, result, metrics)
+					rowData := append([]string{
+						transport.Name,
+						result.QueryType,
+						strconv.Itoa(int(result.ResponseSize)),
+					}, repetitionLabel(countRepetitions, i)...)
+					rowData = append(rowData,
+						strconv.Itoa(int(result.MaxResponseBytes)),
+						fmt.Sprintf("%.4f", result.Latency.LossRate()*100.0),
+						strconv.Itoa(int(result.CountTruncated)),
+						strconv.Itoa(int(result.CountTcpFallbacks)),
+						util.FormatCounts(result.Failures),
+					)
+					rowData = append(rowData, generateLatencyStatsData(result.Latency)...)
+					w.Write(rowData)
+					w.Flush()
+				}
+			}
+		}
+	}
+	createLogFile(filename, contents)
+	if countRepetitions > 1 {
+		summary.write(logfilePrefix+"-dnsMatrixTestSummary"+logfilePostfix, "query type and response size", stabilityThreshold)
+	}
 	fmt.Printf("\n")
 }
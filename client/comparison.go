@@ -0,0 +1,370 @@
+package client
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/tests"
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+// comparisonPath is one of the two network paths or configs of comparison mode
+type comparisonPath struct {
+	name     string
+	config   *types.Configuration // the client configuration with the path's overrides applied
+	proxy    *util.ProxySelector
+	dnsProxy *util.ProxySelector
+}
+
+// comparisonTest is an enabled test whose steps are run on both paths
+type comparisonTest struct {
+	name  string   // e.g. "https burst"
+	steps []string // the label of each step, e.g. the burst size
+	run   func(path *comparisonPath, step int) []stepMetric
+	close func() // closes what run keeps open across the steps, if not nil
+}
+
+// newComparisonPath applies the overrides of one path to the client configuration
+func newComparisonPath(config *types.Configuration, override types.ComparisonPath, defaultName string) (*comparisonPath, error) {
+	pathConfig := *config
+	path := &comparisonPath{name: override.Name, config: &pathConfig}
+	if path.name == "" {
+		path.name = defaultName
+	}
+	if override.ServerHost != "" {
+		pathConfig.Client.ServerHost = override.ServerHost
+	}
+	if override.LocalAddress != "" {
+		pathConfig.Client.LocalAddress = override.LocalAddress
+	}
+	if override.Proxy != nil {
+		pathConfig.Client.Proxy = *override.Proxy
+	}
+	var err error
+	path.proxy, path.dnsProxy, err = proxySelectors(&pathConfig)
+	if err != nil {
+		return nil, fmt.Errorf("path %s: %v", path.name, err)
+	}
+	return path, nil
+}
+
+// describe summarises where the tests of the path go, e.g. "server via 192.168.1.10, proxy socks5://proxy:1080"
+func (e *comparisonPath) describe() string {
+	description := e.config.Client.ServerHost
+	if e.config.Client.LocalAddress != "" {
+		description += " via " + e.config.Client.LocalAddress
+	}
+	proxy := e.config.Client.Proxy
+	switch {
+	case proxy.Direct:
+		description += ", direct"
+	case proxy.PAC != "":
+		description += ", PAC " + util.RedactURL(proxy.PAC)
+	case proxy.URL != "":
+		description += ", proxy " + util.RedactURL(proxy.URL)
+	case proxy.Environment:
+		description += ", proxy from the environment"
+	}
+	return description
+}
+
+// runComparison runs every enabled test that supports comparison mode on paths A and B, interleaving them step by step
+// so that drift over the run affects both equally, and writes the deltas of every metric to a single comparison report
+func runComparison(logfilePrefix string, config *types.Configuration, dnsQueryName string, dnsQueryTypes []uint16) {
+	a, err := newComparisonPath(config, config.Client.Comparison.A, "A")
+	if err != nil {
+		slog.Error("Invalid comparison configuration", "error", err)
+		return
+	}
+	b, err := newComparisonPath(config, config.Client.Comparison.B, "B")
+	if err != nil {
+		slog.Error("Invalid comparison configuration", "error", err)
+		return
+	}
+	restDuration := time.Second * time.Duration(config.Client.Comparison.Rest)
+	if restDuration == 0 {
+		restDuration = time.Second * 5
+	}
+	fmt.Printf("Comparing %s (%s) with %s (%s)\n\n", a.name, a.describe(), b.name, b.describe())
+
+	filename := testResultsDirectory + logfilePrefix + "-abComparison" + config.Client.LogfilePostfix + ".csv"
+	createLogFile(filename, func(w *csv.Writer) {
+		w.Write([]string{"test", "step", "metric", a.name, b.name, "delta", "overhead (%)"})
+		for _, test := range comparisonTests(config, dnsQueryName, dnsQueryTypes) {
+			slog.Info("Starting " + test.name + " comparison")
+			for i, step := range test.steps {
+				// alternate which path goes first, so that neither always runs on a warmer or colder network
+				first, second := a, b
+				if i%2 == 1 {
+					first, second = b, a
+				}
+				results := map[*comparisonPath][]stepMetric{}
+				for _, path := range []*comparisonPath{first, second} {
+					fmt.Printf("[%s] ", path.name)
+					results[path] = test.run(path, i)
+					time.Sleep(restDuration)
+				}
+				for j := range results[a] {
+					metric, valueA := csvMetric(results[a][j])
+					_, valueB := csvMetric(results[b][j])
+					overhead := deltaPercent(valueA, valueB)
+					fmt.Printf("%s %s %s: %s %.3f, %s %.3f (%s%%)\n", test.name, step, metric, a.name, valueA, b.name, valueB, overhead)
+					w.Write([]string{
+						test.name, step, metric,
+						fmt.Sprintf("%.3f", valueA), fmt.Sprintf("%.3f", valueB),
+						fmt.Sprintf("%+.3f", valueB-valueA), overhead,
+					})
+				}
+				w.Flush()
+			}
+			if test.close != nil {
+				test.close()
+			}
+			fmt.Printf("\n")
+		}
+	})
+}
+
+// comparisonTests returns the enabled tests that support comparison mode and warns about the others
+func comparisonTests(config *types.Configuration, dnsQueryName string, dnsQueryTypes []uint16) []comparisonTest {
+	var comparisonTests []comparisonTest
+	t := config.Client.Tests
+	burstSizes := func(i int) int { return (i + 1) * 10 }
+
+	if t.HTTP_Throughput.Enable {
+		comparisonTests = append(comparisonTests, httpThroughputComparison("http throughput", false, config.Client.ServerTCP_HTTP_Port))
+	}
+	if t.HTTPS_Throughput.Enable {
+		comparisonTests = append(comparisonTests, httpThroughputComparison("https throughput", true, config.Client.ServerTCP_HTTPS_Port))
+	}
+	if t.Ping.Enable {
+		comparisonTests = append(comparisonTests, pingComparison(config.Client.ServerPingPort, t.Ping.CountSamples))
+	}
+	if t.HTTP_Burst.Enable {
+		comparisonTests = append(comparisonTests, httpBurstComparison("http burst", false, config.Client.ServerTCP_HTTP_Port, burstSizes,
+			t.HTTP_Burst.Protocol, t.HTTP_Burst.MaxStreams, t.HTTP_Burst.ConnectionPolicy))
+	}
+	if t.HTTPS_Burst.Enable {
+		comparisonTests = append(comparisonTests, httpBurstComparison("https burst", true, config.Client.ServerTCP_HTTPS_Port, burstSizes,
+			t.HTTPS_Burst.Protocol, t.HTTPS_Burst.MaxStreams, t.HTTPS_Burst.ConnectionPolicy))
+	}
+	if t.HTTP_Rate.Enable {
+		comparisonTests = append(comparisonTests, httpRateComparison("http rate", false, config.Client.ServerTCP_HTTP_Port, t.HTTP_Rate.Rates,
+			time.Second*time.Duration(t.HTTP_Rate.Duration), t.HTTP_Rate.Protocol, t.HTTP_Rate.MaxStreams, t.HTTP_Rate.ConnectionPolicy))
+	}
+	if t.HTTPS_Rate.Enable {
+		comparisonTests = append(comparisonTests, httpRateComparison("https rate", true, config.Client.ServerTCP_HTTPS_Port, t.HTTPS_Rate.Rates,
+			time.Second*time.Duration(t.HTTPS_Rate.Duration), t.HTTPS_Rate.Protocol, t.HTTPS_Rate.MaxStreams, t.HTTPS_Rate.ConnectionPolicy))
+	}
+
+	dnsBursts := []struct {
+		enable            bool
+		transportProtocol string
+	}{
+		{t.DNS_UDP_Burst.Enable, "udp"},
+		{t.DNS_TCP_Burst.Enable, "tcp"},
+		{t.DNS_DoT_Burst.Enable, "tcp-tls"},
+		{t.DNS_DoH_Burst.Enable, dohTransport(t.DNS_DoH_Burst.Method)},
+		{t.DNS_DoQ_Burst.Enable, "quic"},
+	}
+	for _, dnsBurst := range dnsBursts {
+		if dnsBurst.enable {
+			comparisonTests = append(comparisonTests, dnsBurstComparison(dnsBurst.transportProtocol, dnsQueryName, dnsQueryTypes, burstSizes))
+		}
+	}
+	dnsRates := []struct {
+		enable            bool
+		transportProtocol string
+		rates             []int
+		duration          uint
+	}{
+		{t.DNS_UDP_Rate.Enable, "udp", t.DNS_UDP_Rate.Rates, t.DNS_UDP_Rate.Duration},
+		{t.DNS_TCP_Rate.Enable, "tcp", t.DNS_TCP_Rate.Rates, t.DNS_TCP_Rate.Duration},
+		{t.DNS_DoT_Rate.Enable, "tcp-tls", t.DNS_DoT_Rate.Rates, t.DNS_DoT_Rate.Duration},
+		{t.DNS_DoH_Rate.Enable, dohTransport(t.DNS_DoH_Rate.Method), t.DNS_DoH_Rate.Rates, t.DNS_DoH_Rate.Duration},
+		{t.DNS_DoQ_Rate.Enable, "quic", t.DNS_DoQ_Rate.Rates, t.DNS_DoQ_Rate.Duration},
+	}
+	for _, dnsRate := range dnsRates {
+		if dnsRate.enable {
+			comparisonTests = append(comparisonTests, dnsRateComparison(dnsRate.transportProtocol, dnsQueryName, dnsQueryTypes, dnsRate.rates, time.Second*time.Duration(dnsRate.duration)))
+		}
+	}
+
+	unsupported := []struct {
+		name    string
+		enabled bool
+	}{
+		{"idle_state_of_device", t.IdleStateOfDevice.Enable},
+		{"idle_state_of_process", t.IdleStateOfProcess.Enable},
+		{"http3_burst", t.HTTP3_Burst.Enable},
+		{"http3_rate", t.HTTP3_Rate.Enable},
+		{"http3_throughput", t.HTTP3_Throughput.Enable},
+		{"icmp_ping", t.ICMP_Ping.Enable},
+		{"tcp_ping", t.TCP_Ping.Enable},
+		{"jitter", t.Jitter.Enable},
+		{"grpc", t.GRPC.Enable},
+		{"ws_echo", t.WS_Echo.Enable},
+		{"wss_echo", t.WSS_Echo.Enable},
+		{"dns_matrix", t.DNS_Matrix.Enable},
+	}
+	for _, test := range unsupported {
+		if test.enabled {
+			slog.Warn("The " + test.name + " test does not support comparison mode and is skipped")
+		}
+	}
+	return comparisonTests
+}
+
+// intSteps labels the steps of a test by the value fn gives each of them
+func intSteps(countSteps int, fn model.Fn) []string {
+	steps := make([]string, countSteps)
+	for i := range steps {
+		steps[i] = strconv.Itoa(fn(i))
+	}
+	return steps
+}
+
+func httpThroughputComparison(name string, isHttps bool, serverPort uint) comparisonTest {
+	serverProtocol := "http://"
+	if isHttps {
+		serverProtocol = "https://"
+	}
+	return comparisonTest{
+		name:  name,
+		steps: []string{"upload", "download"},
+		run: func(path *comparisonPath, step int) []stepMetric {
+			clientOptions := util.HTTPClientOptions{Proxy: path.proxy, LocalAddress: path.config.Client.LocalAddress}
+			var result model.ThroughputTest
+			var err error
+			if step == 0 {
+				result, err = tests.UploadThroughputTest(serverProtocol, path.config.Client.ServerHost, serverPort, path.config.Client.PID, nil, clientOptions)
+			} else {
+				result, err = tests.DownloadThroughputTest(serverProtocol, path.config.Client.ServerHost, serverPort, path.config.Client.PID, nil, clientOptions)
+			}
+			if err != nil {
+				slog.Error(name+" failed", "error", err)
+			}
+			metrics := throughputMetrics(result)
+			fmt.Printf("%s %.0fMb/s\n", result.Type, metrics[0].value)
+			return metrics
+		},
+	}
+}
+
+func pingComparison(serverPort uint, countSamples uint) comparisonTest {
+	return comparisonTest{
+		name:  "ping",
+		steps: []string{strconv.Itoa(int(countSamples))},
+		run: func(path *comparisonPath, step int) []stepMetric {
+			address := net.JoinHostPort(path.config.Client.ServerHost, strconv.Itoa(int(serverPort)))
+			var result model.PingTest
+			conn, err := util.LocalDialer("udp", path.config.Client.LocalAddress, 0).Dial("udp", address)
+			if err != nil {
+				slog.Error("Could not dial", "address", address, "error", err)
+			} else {
+				result = tests.PingTest(conn, countSamples)
+				conn.Close()
+			}
+			fmt.Printf("Ping: %s\n", formatLatencyStats(result.Stats))
+			return pingMetrics(result.Stats)
+		},
+	}
+}
+
+func httpBurstComparison(name string, isHttps bool, serverPort uint, fn model.Fn, protocol string, maxStreams uint, policy types.ConnectionPolicy) comparisonTest {
+	serverProtocol := "http://"
+	if isHttps {
+		serverProtocol = "https://"
+	}
+	return comparisonTest{
+		name:  name,
+		steps: intSteps(10, fn),
+		run: func(path *comparisonPath, step int) []stepMetric {
+			url := fmt.Sprintf("%s%s:%d/download/100000", serverProtocol, path.config.Client.ServerHost, serverPort)
+			clientOptions := httpClientOptions(protocol, maxStreams, path.proxy, path.config.Client.LocalAddress, policy)
+			return burstMetrics(tests.HttpBurstTest(url, fn(step), path.config.Client.PID, isHttps, nil, clientOptions))
+		},
+	}
+}
+
+func httpRateComparison(name string, isHttps bool, serverPort uint, rates []int, testDuration time.Duration, protocol string, maxStreams uint, policy types.ConnectionPolicy) comparisonTest {
+	serverProtocol := "http://"
+	if isHttps {
+		serverProtocol = "https://"
+	}
+	if len(rates) == 0 {
+		rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+	}
+	return comparisonTest{
+		name:  name,
+		steps: intSteps(len(rates), func(i int) int { return rates[i] }),
+		run: func(path *comparisonPath, step int) []stepMetric {
+			url := fmt.Sprintf("%s%s:%d/download/1000", serverProtocol, path.config.Client.ServerHost, serverPort)
+			clientOptions := httpClientOptions(protocol, maxStreams, path.proxy, path.config.Client.LocalAddress, policy)
+			return rateMetrics(tests.HttpRateTest(url, testDuration, rates[step], path.config.Client.PID, isHttps, nil, clientOptions))
+		},
+	}
+}
+
+// dnsComparisonTransports returns the DNS transport of each path, created on first use so that a DNS over QUIC connection
+// lasts across the steps, and a function closing them all
+func dnsComparisonTransports(transportProtocol string) (func(path *comparisonPath) *tests.DnsTransport, func()) {
+	transports := map[*comparisonPath]*tests.DnsTransport{}
+	transport := func(path *comparisonPath) *tests.DnsTransport {
+		if _, ok := transports[path]; !ok {
+			transports[path] = dnsTransport(path.config, transportProtocol, path.dnsProxy)
+		}
+		return transports[path]
+	}
+	closeAll := func() {
+		for _, transport := range transports {
+			if transport != nil {
+				transport.Close()
+			}
+		}
+	}
+	return transport, closeAll
+}
+
+func dnsBurstComparison(transportProtocol string, dnsQueryName string, dnsQueryTypes []uint16, fn model.Fn) comparisonTest {
+	pathTransport, closeTransports := dnsComparisonTransports(transportProtocol)
+	return comparisonTest{
+		name:  "dns " + transportProtocol + " burst",
+		steps: intSteps(10, fn),
+		close: closeTransports,
+		run: func(path *comparisonPath, step int) []stepMetric {
+			transport := pathTransport(path)
+			names := dnsNameSource(path.config, dnsQueryName)
+			if transport == nil || names == nil {
+				return burstMetrics(model.BurstTest{FailureRate: 1})
+			}
+			return burstMetrics(tests.DnsBurstTest(names, dnsQueryTypes, fn(step), path.config.Client.PID, transport, nil))
+		},
+	}
+}
+
+func dnsRateComparison(transportProtocol string, dnsQueryName string, dnsQueryTypes []uint16, rates []int, testDuration time.Duration) comparisonTest {
+	if len(rates) == 0 {
+		rates = []int{10, 20, 30, 40, 50} // default rates if none specified
+	}
+	pathTransport, closeTransports := dnsComparisonTransports(transportProtocol)
+	return comparisonTest{
+		name:  "dns " + transportProtocol + " rate",
+		steps: intSteps(len(rates), func(i int) int { return rates[i] }),
+		close: closeTransports,
+		run: func(path *comparisonPath, step int) []stepMetric {
+			transport := pathTransport(path)
+			names := dnsNameSource(path.config, dnsQueryName)
+			if transport == nil || names == nil {
+				return rateMetrics(model.RateTest{FailureRate: 1})
+			}
+			return rateMetrics(tests.DnsRateTest(names, dnsQueryTypes, testDuration, rates[step], path.config.Client.PID, transport, nil))
+		},
+	}
+}
@@ -0,0 +1,519 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/tests"
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"golang.org/x/term"
+)
+
+const (
+	dashboardRefresh  = 500 * time.Millisecond
+	progressInterval  = 10 * time.Second // between progress lines when the output is not a terminal
+	latencyWindow     = 5 * time.Second  // of the rolling latency
+	historyLength     = 40               // samples of each sparkline, one per second or per process sample
+	processInterval   = 2 * time.Second
+	countLogLines     = 500 // lines of output kept for the log pane
+	maxLatencySamples = 100000
+)
+
+// ansiEscape matches the escape sequences of colored output, which would break the layout of the log pane
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// shownDashboard is the dashboard shown on the terminal, nil if none is, for the paths that exit the run without
+// closing it
+var shownDashboard atomic.Pointer[dashboard]
+
+// dashboard shows the progress of the run: the running test and its last step, the elapsed and estimated remaining
+// time, the live request rate and throughput, the rolling latency, the failures by class and the CPU and RAM of the
+// monitored processes. On a terminal it redraws a full screen view and captures the output of the tests to a log pane
+// and to <name>.log in the run's directory, otherwise it prints a progress line every progressInterval
+type dashboard struct {
+	mutex        sync.Mutex
+	runID        string
+	processNames []string
+	tty          bool
+	terminal     *os.File // standard output before it was captured
+	pipe         *os.File // standing in for standard output while the dashboard is shown
+	consoleLog   *os.File
+	captured     chan struct{} // closed once all output has been captured
+	stop         chan struct{}
+	stopped      sync.WaitGroup
+	restored     sync.Once
+	signals      chan os.Signal // interrupting or terminating the run while the dashboard is on the terminal
+
+	started     time.Time
+	countTests  int
+	durations   []time.Duration // of the tests that finished
+	test        string
+	testStarted time.Time
+	lastStep    string
+
+	countRequests  uint // of the running test
+	countFailures  uint // of the running test
+	failures       model.FailureClasses
+	latencies      []time.Duration // successful requests of the last latencyWindow
+	latencyTimes   []time.Time
+	secondRequests uint   // requests completed in the current second
+	secondBytes    uint64 // bytes transferred in the current second
+	rates          []float64
+	throughputs    []float64 // Mbit/s
+	processes      map[string]*processHistory
+	logLines       []string
+}
+
+// processHistory is the recent CPU and RAM usage of a monitored process
+type processHistory struct {
+	cpu []float64 // percent
+	ram []float64 // MB
+}
+
+// newDashboard starts showing the progress of the run. The output of the tests is written to <name>.log while the
+// dashboard is on a terminal
+func newDashboard(runID string, config *types.Configuration, name string) *dashboard {
+	e := &dashboard{
+		runID:        runID,
+		processNames: config.Client.ProcessNames,
+		tty:          term.IsTerminal(int(os.Stdout.Fd())),
+		terminal:     os.Stdout,
+		stop:         make(chan struct{}),
+		started:      time.Now(),
+		countTests:   countEnabledTests(config),
+		failures:     model.FailureClasses{},
+		processes:    map[string]*processHistory{},
+	}
+	if e.tty {
+		if err := e.capture(testResultsDirectory + name + ".log"); err != nil {
+			slog.Warn("Could not capture the output for the dashboard, showing progress lines instead", "error", err)
+			e.tty = false
+		}
+	}
+	if e.tty {
+		e.terminal.WriteString("\x1b[?1049h\x1b[?25l") // the alternate screen, without a cursor
+		shownDashboard.Store(e)
+		e.signals = make(chan os.Signal, 1)
+		signal.Notify(e.signals, os.Interrupt, syscall.SIGTERM)
+		go e.restoreOnSignal()
+	}
+	tests.SetProgress(e)
+
+	e.stopped.Add(1)
+	go e.refresh()
+	if len(e.processNames) > 0 {
+		e.stopped.Add(1)
+		go e.sampleProcesses()
+	}
+	return e
+}
+
+// countEnabledTests returns the number of tests enabled in the configuration
+func countEnabledTests(config *types.Configuration) int {
+	count := 0
+	testsValue := reflect.ValueOf(config.Client.Tests)
+	for i := 0; i < testsValue.NumField(); i++ {
+		if enable := testsValue.Field(i).FieldByName("Enable"); enable.IsValid() && enable.Bool() {
+			count++
+		}
+	}
+	return count
+}
+
+// capture replaces standard output, which the log is written to, with a pipe, whose output is written to the file
+// at filename and kept for the log pane
+func (e *dashboard) capture(filename string) error {
+	consoleLog, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		consoleLog.Close()
+		return err
+	}
+	e.consoleLog, e.pipe, e.captured = consoleLog, writer, make(chan struct{})
+	os.Stdout = writer
+	go func() {
+		defer close(e.captured)
+		lines := bufio.NewReader(reader)
+		for {
+			line, err := lines.ReadString('\n')
+			if line != "" {
+				consoleLog.WriteString(line)
+				line = strings.TrimRight(ansiEscape.ReplaceAllString(line, ""), "\r\n")
+				e.mutex.Lock()
+				e.logLines = append(e.logLines, line)
+				if len(e.logLines) > countLogLines {
+					e.logLines = e.logLines[len(e.logLines)-countLogLines:]
+				}
+				e.mutex.Unlock()
+			}
+			if err != nil {
+				break
+			}
+		}
+		reader.Close()
+	}()
+	return nil
+}
+
+func (e *dashboard) name() string {
+	return "Dashboard"
+}
+
+// Request records the outcome of a request of the running test
+func (e *dashboard) Request(latency time.Duration, failure string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.countRequests++
+	e.secondRequests++
+	if failure != "" {
+		e.countFailures++
+		e.failures[failure]++
+		return
+	}
+	if len(e.latencies) >= maxLatencySamples {
+		e.latencies, e.latencyTimes = e.latencies[1:], e.latencyTimes[1:]
+	}
+	e.latencies = append(e.latencies, latency)
+	e.latencyTimes = append(e.latencyTimes, time.Now())
+}
+
+// Transferred records the bytes transferred by the running test
+func (e *dashboard) Transferred(countBytes int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.secondBytes += uint64(countBytes)
+}
+
+func (e *dashboard) startTest(test string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.test, e.testStarted, e.lastStep = test, time.Now(), ""
+	e.countRequests, e.countFailures, e.failures = 0, 0, model.FailureClasses{}
+}
+
+// export shows the metrics of the step that finished last
+func (e *dashboard) export(record stepRecord) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var metrics []string
+	for _, name := range sortedKeys(record.Metrics) {
+		metrics = append(metrics, fmt.Sprintf("%s %.3g", name, record.Metrics[name]))
+	}
+	e.lastStep = fmt.Sprintf("%s (#%d): %s", record.Step, record.Repetition, strings.Join(metrics, ", "))
+	return nil
+}
+
+func (e *dashboard) endTest() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.durations = append(e.durations, time.Since(e.testStarted))
+	e.test = ""
+	return nil
+}
+
+// close stops showing the dashboard and restores standard output
+func (e *dashboard) close() error {
+	e.restore()
+	if e.pipe == nil {
+		return nil
+	}
+	// the tests have finished, so nothing else writes to standard output while it is replaced
+	os.Stdout = e.terminal
+	signal.Stop(e.signals)
+	close(e.signals)
+	shownDashboard.CompareAndSwap(e, nil)
+	slog.Info("The output of the tests is in " + e.consoleLog.Name())
+	return e.consoleLog.Close()
+}
+
+// restore stops showing the dashboard and restores the terminal, once. Standard output is left on the closed pipe, as
+// other goroutines may be writing to it, until close restores it
+func (e *dashboard) restore() {
+	e.restored.Do(func() {
+		close(e.stop)
+		e.stopped.Wait()
+		tests.SetProgress(nil)
+		if e.pipe == nil {
+			return
+		}
+		e.terminal.WriteString("\x1b[?25h\x1b[?1049l")
+		e.pipe.Close()
+		<-e.captured
+	})
+}
+
+// restoreOnSignal restores the terminal and exits when the run is interrupted or terminated, which would otherwise
+// leave the terminal on the alternate screen without a cursor
+func (e *dashboard) restoreOnSignal() {
+	received, ok := <-e.signals
+	if !ok {
+		return
+	}
+	e.restore()
+	// written to the terminal directly, as standard output is only restored by close
+	fmt.Fprintf(e.terminal, "Run stopped by %s, the output of the tests is in %s\n", received, e.consoleLog.Name())
+	code := 1
+	if number, ok := received.(syscall.Signal); ok {
+		code = 128 + int(number)
+	}
+	os.Exit(code)
+}
+
+// restoreTerminal restores the terminal if the dashboard is shown, before the run exits without closing it
+func restoreTerminal() {
+	if e := shownDashboard.Load(); e != nil {
+		e.restore()
+	}
+}
+
+// fatal restores the terminal if the dashboard is shown and exits like log.Fatalln
+func fatal(v ...any) {
+	restoreTerminal()
+	log.Fatalln(v...)
+}
+
+// refresh redraws the dashboard, or prints a progress line when not on a terminal, until the dashboard is closed
+func (e *dashboard) refresh() {
+	defer e.stopped.Done()
+	redraw := time.NewTicker(dashboardRefresh)
+	defer redraw.Stop()
+	second := time.NewTicker(time.Second)
+	defer second.Stop()
+	progress := time.NewTicker(progressInterval)
+	defer progress.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-second.C:
+			e.mutex.Lock()
+			e.rates = appendHistory(e.rates, float64(e.secondRequests))
+			e.throughputs = appendHistory(e.throughputs, float64(e.secondBytes)*8/1e6)
+			e.secondRequests, e.secondBytes = 0, 0
+			e.mutex.Unlock()
+		case <-redraw.C:
+			if e.tty {
+				e.terminal.WriteString(e.frame())
+			}
+		case <-progress.C:
+			if !e.tty {
+				fmt.Println(e.progressLine())
+			}
+		}
+	}
+}
+
+// sampleProcesses samples the CPU and RAM usage of the monitored processes until the dashboard is closed
+func (e *dashboard) sampleProcesses() {
+	defer e.stopped.Done()
+	ticker := time.NewTicker(processInterval)
+	defer ticker.Stop()
+	for {
+		usage := util.GetCPUandRAMForProcesses(e.processNames)
+		e.mutex.Lock()
+		for name, u := range usage {
+			history := e.processes[name]
+			if history == nil {
+				history = &processHistory{}
+				e.processes[name] = history
+			}
+			history.cpu = appendHistory(history.cpu, u.Cpu*100)
+			history.ram = appendHistory(history.ram, float64(u.Ram)/1e6)
+		}
+		e.mutex.Unlock()
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func appendHistory(history []float64, value float64) []float64 {
+	history = append(history, value)
+	if len(history) > historyLength {
+		history = history[len(history)-historyLength:]
+	}
+	return history
+}
+
+// rollingLatency returns the statistics of the latency of the requests of the last latencyWindow. The caller holds the
+// mutex
+func (e *dashboard) rollingLatency() model.LatencyStats {
+	cutoff := time.Now().Add(-latencyWindow)
+	i := sort.Search(len(e.latencyTimes), func(i int) bool { return e.latencyTimes[i].After(cutoff) })
+	e.latencies, e.latencyTimes = e.latencies[i:], e.latencyTimes[i:]
+	return util.ComputeLatencyStats(append([]time.Duration{}, e.latencies...))
+}
+
+// remaining estimates the time until the run finishes from the mean duration of the tests that finished. The caller
+// holds the mutex
+func (e *dashboard) remaining() string {
+	if len(e.durations) == 0 {
+		return "unknown"
+	}
+	var total time.Duration
+	for _, duration := range e.durations {
+		total += duration
+	}
+	mean := total / time.Duration(len(e.durations))
+	remaining := time.Duration(e.countTests-len(e.durations)) * mean
+	if e.test != "" {
+		remaining -= min(time.Since(e.testStarted), mean)
+	}
+	return "~" + formatElapsed(max(remaining, 0))
+}
+
+// testPosition returns the number of the running test, or of the last test if none is running. The caller holds the
+// mutex
+func (e *dashboard) testPosition() string {
+	number := len(e.durations)
+	if e.test != "" {
+		number++
+	}
+	return fmt.Sprintf("%d of %d", number, e.countTests)
+}
+
+func (e *dashboard) progressLine() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	latency := e.rollingLatency()
+	line := fmt.Sprintf("Progress: test %s %s, elapsed %s, remaining %s", e.testPosition(), e.test, formatElapsed(time.Since(e.started)), e.remaining())
+	if len(e.rates) > 0 {
+		line += fmt.Sprintf(", %.0f req/s, %.1f Mbit/s", e.rates[len(e.rates)-1], e.throughputs[len(e.throughputs)-1])
+	}
+	if latency.CountSamples > 0 {
+		line += fmt.Sprintf(", p99 %.3f ms", durationToMilliseconds(latency.P99))
+	}
+	return line + fmt.Sprintf(", %d failures", e.countFailures)
+}
+
+// frame draws the dashboard to fit the terminal
+func (e *dashboard) frame() string {
+	width, height, err := term.GetSize(int(e.terminal.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	add("\x1b[1mNetwork performance tester\x1b[0m  run %s  elapsed %s  remaining %s", e.runID, formatElapsed(time.Since(e.started)), e.remaining())
+	if e.test != "" {
+		add("Test %s: \x1b[1m%s\x1b[0m  running for %s", e.testPosition(), e.test, formatElapsed(time.Since(e.testStarted)))
+	} else {
+		add("Test %s: between tests", e.testPosition())
+	}
+	lastStep := e.lastStep
+	if lastStep == "" {
+		lastStep = "none yet"
+	}
+	add("Last step   %s", lastStep)
+	add("")
+
+	rate, throughput := 0.0, 0.0
+	if len(e.rates) > 0 {
+		rate, throughput = e.rates[len(e.rates)-1], e.throughputs[len(e.throughputs)-1]
+	}
+	failureRate := 0.0
+	if e.countRequests > 0 {
+		failureRate = float64(e.countFailures) / float64(e.countRequests) * 100
+	}
+	add("Requests    %8.0f/s      %s  %d in this test, %d failed (%.2f%%)", rate, padRight(util.Sparkline(e.rates), historyLength), e.countRequests, e.countFailures, failureRate)
+	add("Throughput  %8.1f Mbit/s %s", throughput, util.Sparkline(e.throughputs))
+	if latency := e.rollingLatency(); latency.CountSamples > 0 {
+		add("Latency     p50 %.3f ms  p95 %.3f ms  p99 %.3f ms  max %.3f ms  (%d in the last %s)", durationToMilliseconds(latency.P50), durationToMilliseconds(latency.P95), durationToMilliseconds(latency.P99), durationToMilliseconds(latency.Max), latency.CountSamples, latencyWindow)
+	} else {
+		add("Latency     no responses in the last %s", latencyWindow)
+	}
+	if len(e.failures) == 0 {
+		add("Failures    none")
+	} else {
+		var classes []string
+		for _, class := range sortedKeys(e.failures) {
+			classes = append(classes, fmt.Sprintf("%s %d", class, e.failures[class]))
+		}
+		add("Failures    \x1b[1;31m%s\x1b[0m", strings.Join(classes, ", "))
+	}
+	for _, name := range sortedKeys(e.processes) {
+		history := e.processes[name]
+		add("Process     %-16s CPU %6.1f%% %s  RAM %8.1f MB %s", name, history.cpu[len(history.cpu)-1], padRight(util.Sparkline(history.cpu), historyLength), history.ram[len(history.ram)-1], util.Sparkline(history.ram))
+	}
+	add("%s", strings.Repeat("─", width))
+
+	countLog := height - len(lines)
+	logLines := e.logLines
+	if len(logLines) > countLog {
+		logLines = logLines[len(logLines)-countLog:]
+	}
+	lines = append(lines, logLines...)
+
+	var frame strings.Builder
+	frame.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		frame.WriteString(truncate(line, width) + "\x1b[K")
+		if i < height-1 {
+			frame.WriteString("\n")
+		}
+	}
+	frame.WriteString("\x1b[J")
+	return frame.String()
+}
+
+// truncate shortens line to width visible characters, keeping its escape sequences
+func truncate(line string, width int) string {
+	var out strings.Builder
+	visible := 0
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' {
+			if loc := ansiEscape.FindStringIndex(line[i:]); loc != nil && loc[0] == 0 {
+				out.WriteString(line[i : i+loc[1]])
+				i += loc[1]
+				continue
+			}
+		}
+		if visible == width {
+			out.WriteString("\x1b[0m")
+			break
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		out.WriteRune(r)
+		visible++
+		i += size
+	}
+	return out.String()
+}
+
+// padRight pads s with spaces to width characters
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0))
+}
+
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+)
+
+// resultExporter receives every step record of a run, e.g. to send it to a time-series database. The recorder calls it
+// from one goroutine at a time
+type resultExporter interface {
+	name() string
+	startTest(test string)
+	export(record stepRecord) error
+	endTest() error // called when the running test finishes, e.g. to flush the records of the test
+	close() error   // called when the run finishes
+}
+
+// stepStarter is implemented by the exporters that show the step in progress, which the recorder tells when a step
+// starts
+type stepStarter interface {
+	startStep(step string)
+}
+
+// monitoringSample is a sample of the CPU and RAM usage of the monitored processes, taken every processInterval while a
+// test runs
+type monitoringSample struct {
+	RunID     string
+	Host      string
+	Label     string
+	TestID    string
+	Step      string // the step in progress
+	Timestamp time.Time
+	Processes model.ProcessCpuAndRam // by process name, of the processes that are running
+}
+
+// sampleExporter is implemented by the exporters that receive the monitoring samples of the run besides its steps
+type sampleExporter interface {
+	exportSample(sample monitoringSample) error
+}
+
+// newExporters returns the exporters enabled in the configuration
+func newExporters(runID string, config *types.Configuration) []resultExporter {
+	var exporters []resultExporter
+	if config.Client.Dashboard {
+		// first, so that the output of the other exporters is not captured once the dashboard closes
+		exporters = append(exporters, newDashboard(runID, config, runID+"-console"+config.Client.LogfilePostfix))
+	}
+	if config.Client.Metrics.Listen != "" || config.Client.Metrics.PushURL != "" {
+		metrics := newPrometheusMetrics(runID, config.Client.Metrics.PushURL, config.Client.Metrics.Job)
+		if config.Client.Metrics.Listen != "" {
+			if err := metrics.serve(config.Client.Metrics.Listen); err != nil {
+				slog.Error("Could not serve Prometheus metrics", "listen", config.Client.Metrics.Listen, "error", err)
+			}
+		}
+		exporters = append(exporters, metrics)
+	}
+	influxDB := config.Client.Exporters.InfluxDB
+	if influxDB.File != "" || influxDB.URL != "" {
+		exporter, err := newInfluxDBExporter(influxDB.File, influxDB.URL, influxDB.Token)
+		if err != nil {
+			slog.Error("Could not export to InfluxDB", "error", err)
+		} else {
+			exporters = append(exporters, exporter)
+		}
+	}
+	if config.Client.Exporters.OTLP.Endpoint != "" {
+		exporters = append(exporters, newOTLPExporter(config.Client.Exporters.OTLP.Endpoint, config.Client.Exporters.OTLP.Headers))
+	}
+	return exporters
+}
+
+// resultDetails returns the number of requests, failure rate, latency and monitored processes of the result of a step,
+// where it has them
+func resultDetails(record stepRecord) (countRequests uint, failureRate float64, latency *model.LatencyStats, processes model.ProcessCpuAndRam) {
+	switch result := record.Result.(type) {
+	case model.BurstTest:
+		return result.CountRequests, result.FailureRate, &result.Latency, result.ProcessCpuAndRam
+	case model.RateTest:
+		return result.CountRequests, result.FailureRate, &result.Latency, result.ProcessCpuAndRam
+	case model.ThroughputTest:
+		return 0, 0, nil, result.ProcessCpuAndRam
+	case model.WebSocketTest:
+		return 0, 0, &result.Latency, result.ProcessCpuAndRam
+	case model.PingTest:
+		return 0, 0, &result.Stats, nil
+	case model.DnsMatrixTest:
+		return 0, 0, &result.Latency, nil
+	case *model.CpuAndRam:
+		if result != nil {
+			return 0, 0, nil, model.ProcessCpuAndRam{record.Step: result}
+		}
+	}
+	return 0, 0, nil, nil
+}
+
+// metricUnit returns the unit of a metric from the suffix of its name, in UCUM as OTLP expects
+func metricUnit(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_ms"):
+		return "ms"
+	case strings.HasSuffix(name, "_mbps"):
+		return "Mbit/s"
+	case strings.HasSuffix(name, "_percent"):
+		return "%"
+	case strings.HasSuffix(name, "_mb"):
+		return "MBy"
+	case strings.HasSuffix(name, "_per_second"):
+		return "1/s"
+	}
+	return "1"
+}
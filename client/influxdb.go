@@ -0,0 +1,161 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxDBExporter writes the records and monitoring samples of a run in InfluxDB line protocol to a file in the run's directory and/or to the
+// write endpoint of an InfluxDB server, once per test
+type influxDBExporter struct {
+	file    *os.File
+	url     string
+	token   string
+	pending bytes.Buffer // lines not yet written to url
+}
+
+func newInfluxDBExporter(file string, url string, token string) (*influxDBExporter, error) {
+	e := &influxDBExporter{url: url, token: token}
+	if file != "" {
+		f, err := os.Create(testResultsDirectory + file)
+		if err != nil {
+			return nil, err
+		}
+		e.file = f
+	}
+	return e, nil
+}
+
+func (e *influxDBExporter) name() string {
+	return "InfluxDB"
+}
+
+func (e *influxDBExporter) startTest(test string) {}
+
+// export writes an npt_step line with the metrics of the step and an npt_process line for each monitored process
+func (e *influxDBExporter) export(record stepRecord) error {
+	tags := map[string]string{"run_id": record.RunID, "host": record.Host, "label": record.Label, "test": record.TestID, "step": record.Step}
+	timestamp := record.Timestamp.UnixNano()
+	var lines bytes.Buffer
+
+	fields := map[string]string{"repetition": strconv.Itoa(record.Repetition) + "i"}
+	for name, value := range record.Metrics {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			fields[name] = strconv.FormatFloat(value, 'g', -1, 64)
+		}
+	}
+	writeInfluxLine(&lines, "npt_step", tags, fields, timestamp)
+
+	_, _, _, processes := resultDetails(record)
+	for _, process := range sortedKeys(processes) {
+		usage := processes[process]
+		if usage == nil {
+			continue
+		}
+		tags["process"] = process
+		writeInfluxLine(&lines, "npt_process", tags, map[string]string{
+			"cpu_ratio":     strconv.FormatFloat(usage.Cpu, 'g', -1, 64),
+			"ram_bytes":     strconv.Itoa(int(usage.Ram)) + "i",
+			"process_count": strconv.Itoa(usage.ProcessCount) + "i",
+		}, timestamp)
+	}
+
+	return e.write(lines.Bytes())
+}
+
+// exportSample writes an npt_monitor line for each process of the sample
+func (e *influxDBExporter) exportSample(sample monitoringSample) error {
+	tags := map[string]string{"run_id": sample.RunID, "host": sample.Host, "label": sample.Label, "test": sample.TestID, "step": sample.Step}
+	var lines bytes.Buffer
+	for _, process := range sortedKeys(sample.Processes) {
+		usage := sample.Processes[process]
+		tags["process"] = process
+		writeInfluxLine(&lines, "npt_monitor", tags, map[string]string{
+			"cpu_ratio":     strconv.FormatFloat(usage.Cpu, 'g', -1, 64),
+			"ram_bytes":     strconv.Itoa(int(usage.Ram)) + "i",
+			"process_count": strconv.Itoa(usage.ProcessCount) + "i",
+		}, sample.Timestamp.UnixNano())
+	}
+	return e.write(lines.Bytes())
+}
+
+// write adds lines to those pending for the write endpoint and writes them to the file
+func (e *influxDBExporter) write(lines []byte) error {
+	if e.url != "" {
+		e.pending.Write(lines)
+	}
+	if e.file != nil {
+		if _, err := e.file.Write(lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *influxDBExporter) endTest() error {
+	return e.flush()
+}
+
+func (e *influxDBExporter) close() error {
+	err := e.flush()
+	if e.file != nil {
+		e.file.Close()
+	}
+	return err
+}
+
+// flush writes the pending lines to the write endpoint
+func (e *influxDBExporter) flush() error {
+	if e.url == "" || e.pending.Len() == 0 {
+		return nil
+	}
+	defer e.pending.Reset()
+	request, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(e.pending.Bytes()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		request.Header.Set("Authorization", "Token "+e.token)
+	}
+	response, err := (&http.Client{Timeout: 30 * time.Second}).Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", e.url, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// writeInfluxLine writes a line of line protocol, leaving out empty tags. fields are already formatted
+func writeInfluxLine(w *bytes.Buffer, measurement string, tags map[string]string, fields map[string]string, timestamp int64) {
+	w.WriteString(strings.NewReplacer(",", `\,`, " ", `\ `).Replace(measurement))
+	for _, key := range sortedKeys(tags) {
+		if tags[key] != "" {
+			fmt.Fprintf(w, ",%s=%s", escapeInfluxKey(key), escapeInfluxKey(tags[key]))
+		}
+	}
+	for i, key := range sortedKeys(fields) {
+		separator := ","
+		if i == 0 {
+			separator = " "
+		}
+		fmt.Fprintf(w, "%s%s=%s", separator, escapeInfluxKey(key), fields[key])
+	}
+	fmt.Fprintf(w, " %d\n", timestamp)
+}
+
+// escapeInfluxKey escapes a tag key, tag value or field key
+func escapeInfluxKey(key string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(key)
+}
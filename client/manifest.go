@@ -0,0 +1,141 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+	"github.com/shirou/gopsutil/host"
+	"gopkg.in/yaml.v2"
+)
+
+// manifest describes a run and lists the files it wrote, so that its directory is self-describing when archived
+type manifest struct {
+	RunID        string          `json:"run_id"`
+	Status       string          `json:"status"` // passed, failed if an assertion failed, or invalid if the configuration was
+	Started      time.Time       `json:"started"`
+	Ended        time.Time       `json:"ended"`
+	ToolVersion  string          `json:"tool_version"`
+	GitCommit    string          `json:"git_commit,omitempty"` // with a -dirty suffix if built from modified sources
+	GoVersion    string          `json:"go_version"`
+	Host         manifestHost    `json:"host"`
+	ConfigSHA256 string          `json:"config_sha256"` // of the configuration as loaded, including environment overrides
+	Device       *model.DUT_Info `json:"device,omitempty"`
+	Tests        []testStatus    `json:"tests"`
+	Artifacts    []artifact      `json:"artifacts"`
+}
+
+type manifestHost struct {
+	Hostname        string `json:"hostname"`
+	OS              string `json:"os"`
+	Arch            string `json:"arch"`
+	Platform        string `json:"platform,omitempty"` // e.g. ubuntu or darwin
+	PlatformVersion string `json:"platform_version,omitempty"`
+	KernelVersion   string `json:"kernel_version,omitempty"`
+}
+
+// testStatus is the outcome of a test: passed, failed if an assertion failed, or no results if it recorded no steps,
+// e.g. because it could not reach the server
+type testStatus struct {
+	TestID          string    `json:"test_id"`
+	Status          string    `json:"status"`
+	Started         time.Time `json:"started"`
+	Ended           time.Time `json:"ended"`
+	CountSteps      int       `json:"count_steps"`
+	CountAssertions int       `json:"count_assertions"`
+	CountFailures   int       `json:"count_failures"`
+}
+
+// artifact is a file of the run, relative to the run's directory
+type artifact struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+func newManifest(runID string, config *types.Configuration) *manifest {
+	e := &manifest{RunID: runID, Started: time.Now().UTC(), GoVersion: runtime.Version(), Tests: []testStatus{}}
+	e.ToolVersion, e.GitCommit = toolVersion()
+	e.Host = manifestHost{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	if info, err := host.Info(); err == nil {
+		e.Host.Hostname = info.Hostname
+		e.Host.Platform = info.Platform
+		e.Host.PlatformVersion = info.PlatformVersion
+		e.Host.KernelVersion = info.KernelVersion
+	}
+	if data, err := yaml.Marshal(config); err == nil {
+		sum := sha256.Sum256(data)
+		e.ConfigSHA256 = hex.EncodeToString(sum[:])
+	}
+	return e
+}
+
+// toolVersion returns the module version and VCS revision the client was built from. The revision is empty unless the
+// build stamped it, as go build does in a git checkout but go run does not, rather than taken from the working
+// directory, which need not hold the client's sources
+func toolVersion() (string, string) {
+	version, commit, modified := "unknown", "", false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+	}
+	if commit != "" && modified {
+		commit += "-dirty"
+	}
+	return version, commit
+}
+
+// write writes manifest.json to directory, listing the tests that ran and every other file in directory. The status is
+// passed or failed as given unless it was already set, e.g. to invalid
+func (e *manifest) write(directory string, passed bool, tests []testStatus) {
+	e.Ended = time.Now().UTC()
+	if e.Status == "" {
+		e.Status = "passed"
+		if !passed {
+			e.Status = "failed"
+		}
+	}
+	e.Tests = append(e.Tests, tests...)
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		slog.Error("Could not list the results", "directory", directory, "error", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == "manifest.json" {
+			continue
+		}
+		file := artifact{Path: entry.Name()}
+		if f, err := os.Open(directory + entry.Name()); err == nil {
+			hash := sha256.New()
+			file.Bytes, _ = io.Copy(hash, f)
+			file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+			f.Close()
+		}
+		e.Artifacts = append(e.Artifacts, file)
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		slog.Error("Could not encode the manifest", "error", err)
+		return
+	}
+	if err := os.WriteFile(directory+"manifest.json", append(data, '\n'), 0644); err != nil {
+		slog.Error("Could not write the manifest", "error", err)
+	}
+}
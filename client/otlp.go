@@ -0,0 +1,171 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otlpExporter sends the metrics of every record and monitoring sample of a run as OTLP gauges to an OTLP/HTTP endpoint in its JSON encoding,
+// once per test
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	host     string
+	metrics  []*otlpMetric // pending, in the order they were first seen
+	byName   map[string]*otlpMetric
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpKeyValue `json:"attributes"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     float64        `json:"asDouble"`
+}
+
+type otlpMetric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit"`
+	Gauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+func newOTLPExporter(endpoint string, headers map[string]string) *otlpExporter {
+	return &otlpExporter{endpoint: endpoint, headers: headers, byName: map[string]*otlpMetric{}}
+}
+
+func (e *otlpExporter) name() string {
+	return "OTLP"
+}
+
+func (e *otlpExporter) startTest(test string) {}
+
+// export adds a data point of npt.<metric> for each metric of the step, and of npt.process.cpu_ratio and
+// npt.process.ram_bytes for each monitored process
+func (e *otlpExporter) export(record stepRecord) error {
+	e.host = record.Host
+	attributes := append(otlpAttributes(record.RunID, record.Label, record.TestID, record.Step),
+		otlpKeyValue{"repetition", otlpAnyValue{strconv.Itoa(record.Repetition)}})
+	timestamp := strconv.FormatInt(record.Timestamp.UnixNano(), 10)
+	for _, name := range sortedKeys(record.Metrics) {
+		e.add("npt."+name, metricUnit(name), otlpDataPoint{attributes, timestamp, record.Metrics[name]})
+	}
+	_, _, _, processes := resultDetails(record)
+	for _, process := range sortedKeys(processes) {
+		usage := processes[process]
+		if usage == nil {
+			continue
+		}
+		processAttributes := append(append([]otlpKeyValue{}, attributes...), otlpKeyValue{"process", otlpAnyValue{process}})
+		e.add("npt.process.cpu_ratio", "1", otlpDataPoint{processAttributes, timestamp, usage.Cpu})
+		e.add("npt.process.ram_bytes", "By", otlpDataPoint{processAttributes, timestamp, float64(usage.Ram)})
+	}
+	return nil
+}
+
+// exportSample adds a data point of npt.monitor.cpu_ratio and npt.monitor.ram_bytes for each process of the sample
+func (e *otlpExporter) exportSample(sample monitoringSample) error {
+	e.host = sample.Host
+	attributes := otlpAttributes(sample.RunID, sample.Label, sample.TestID, sample.Step)
+	timestamp := strconv.FormatInt(sample.Timestamp.UnixNano(), 10)
+	for _, process := range sortedKeys(sample.Processes) {
+		usage := sample.Processes[process]
+		processAttributes := append(append([]otlpKeyValue{}, attributes...), otlpKeyValue{"process", otlpAnyValue{process}})
+		e.add("npt.monitor.cpu_ratio", "1", otlpDataPoint{processAttributes, timestamp, usage.Cpu})
+		e.add("npt.monitor.ram_bytes", "By", otlpDataPoint{processAttributes, timestamp, float64(usage.Ram)})
+	}
+	return nil
+}
+
+// otlpAttributes returns the attributes of the data points of a step or sample
+func otlpAttributes(runID string, label string, test string, step string) []otlpKeyValue {
+	attributes := []otlpKeyValue{
+		{"run_id", otlpAnyValue{runID}},
+		{"test", otlpAnyValue{test}},
+		{"step", otlpAnyValue{step}},
+	}
+	if label != "" {
+		attributes = append(attributes, otlpKeyValue{"label", otlpAnyValue{label}})
+	}
+	return attributes
+}
+
+// add adds a data point to the pending metric name, leaving out non-finite values, e.g. the NaN failure rate of a step
+// that sent no requests, which JSON cannot encode
+func (e *otlpExporter) add(name string, unit string, point otlpDataPoint) {
+	if math.IsNaN(point.AsDouble) || math.IsInf(point.AsDouble, 0) {
+		return
+	}
+	metric, ok := e.byName[name]
+	if !ok {
+		metric = &otlpMetric{Name: name, Unit: unit}
+		e.byName[name] = metric
+		e.metrics = append(e.metrics, metric)
+	}
+	metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+}
+
+func (e *otlpExporter) endTest() error {
+	return e.flush()
+}
+
+func (e *otlpExporter) close() error {
+	return e.flush()
+}
+
+// flush sends the pending data points in an ExportMetricsServiceRequest
+func (e *otlpExporter) flush() error {
+	if len(e.metrics) == 0 {
+		return nil
+	}
+	defer func() {
+		e.metrics = nil
+		e.byName = map[string]*otlpMetric{}
+	}()
+	scope := map[string]any{"name": "network-performance-tester"}
+	body, err := json.Marshal(map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpKeyValue{
+				{"service.name", otlpAnyValue{"network-performance-tester"}},
+				{"host.name", otlpAnyValue{e.host}},
+			}},
+			"scopeMetrics": []any{map[string]any{"scope": scope, "metrics": e.metrics}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := (&http.Client{Timeout: 30 * time.Second}).Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", e.endpoint, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+func TestOTLPExport(t *testing.T) {
+	var requests []map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, request)
+	}))
+	defer collector.Close()
+
+	exporter := newOTLPExporter(collector.URL, map[string]string{"Authorization": "Bearer token"})
+	timestamp := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	exporter.startTest("dns_udp_rate")
+	// a step that sent no requests has a NaN failure rate
+	exporter.export(stepRecord{RunID: "20240131T120000Z", Host: "dut", TestID: "dns_udp_rate", Step: "0", Repetition: 1, Timestamp: timestamp,
+		Metrics: map[string]float64{"failure_rate": math.NaN(), "requests_per_second": 0}})
+	exporter.export(stepRecord{RunID: "20240131T120000Z", Host: "dut", TestID: "dns_udp_rate", Step: "10", Repetition: 1, Timestamp: timestamp,
+		Metrics: map[string]float64{"failure_rate": 0.5, "requests_per_second": 10}})
+	exporter.exportSample(monitoringSample{RunID: "20240131T120000Z", Host: "dut", TestID: "dns_udp_rate", Step: "10", Timestamp: timestamp,
+		Processes: model.ProcessCpuAndRam{"server": {Cpu: 0.25, Ram: 1000, ProcessCount: 1}}})
+	if err := exporter.endTest(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(requests))
+	}
+
+	countPoints := map[string]int{}
+	for _, resourceMetrics := range requests[0]["resourceMetrics"].([]any) {
+		for _, scopeMetrics := range resourceMetrics.(map[string]any)["scopeMetrics"].([]any) {
+			for _, metric := range scopeMetrics.(map[string]any)["metrics"].([]any) {
+				metric := metric.(map[string]any)
+				countPoints[metric["name"].(string)] += len(metric["gauge"].(map[string]any)["dataPoints"].([]any))
+			}
+		}
+	}
+	for name, want := range map[string]int{"npt.failure_rate": 1, "npt.requests_per_second": 2, "npt.monitor.cpu_ratio": 1, "npt.monitor.ram_bytes": 1} {
+		if countPoints[name] != want {
+			t.Errorf("sent %d data points of %s, want %d", countPoints[name], name, want)
+		}
+	}
+
+	// nothing is pending after the flush
+	if err := exporter.close(); err != nil || len(requests) != 1 {
+		t.Errorf("close sent %d requests and returned %v, want none", len(requests)-1, err)
+	}
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+// prometheusMetrics keeps the metrics of the run in progress in the Prometheus text exposition format, for the /metrics
+// endpoint and the Pushgateway
+type prometheusMetrics struct {
+	mutex      sync.Mutex
+	runID      string
+	pushURL    string // Pushgateway the final metrics are pushed to on close, none if empty
+	job        string
+	test       string
+	step       string
+	countSteps map[string]float64               // by test
+	requests   map[[2]string]float64            // by test and outcome, success or failure
+	latency    map[string]*latencyHistogram     // by test
+	gauges     map[string]map[[2]string]float64 // by metric name, then by test and step
+	processCpu map[[2]string]float64            // by test and process name
+	processRam map[[2]string]float64            // by test and process name
+	server     *http.Server
+}
+
+// latencyHistogram accumulates the latency histograms of the steps of a test
+type latencyHistogram struct {
+	buckets []uint64 // cumulative count at or below each of model.LatencyBucketBounds
+	count   uint64
+	sum     float64 // seconds
+}
+
+func newPrometheusMetrics(runID string, pushURL string, job string) *prometheusMetrics {
+	if job == "" {
+		job = "network_performance_tester"
+	}
+	return &prometheusMetrics{
+		runID:      runID,
+		pushURL:    pushURL,
+		job:        job,
+		countSteps: map[string]float64{},
+		requests:   map[[2]string]float64{},
+		latency:    map[string]*latencyHistogram{},
+		gauges:     map[string]map[[2]string]float64{},
+		processCpu: map[[2]string]float64{},
+		processRam: map[[2]string]float64{},
+	}
+}
+
+// serve serves the metrics at http://<address>/metrics until close
+func (e *prometheusMetrics) serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e.write(w)
+	})
+	e.server = &http.Server{Handler: mux}
+	go e.server.Serve(listener)
+	slog.Info("Serving Prometheus metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+	return nil
+}
+
+func (e *prometheusMetrics) name() string {
+	return "Prometheus"
+}
+
+// close pushes the final metrics if a Pushgateway is configured and stops serving them
+func (e *prometheusMetrics) close() error {
+	if e.server != nil {
+		defer e.server.Close()
+	}
+	if e.pushURL == "" {
+		return nil
+	}
+	return e.push()
+}
+
+// push replaces the metrics of the job, grouped by this host, on the Pushgateway
+func (e *prometheusMetrics) push() error {
+	instance, _ := os.Hostname()
+	if instance == "" {
+		instance = "unknown"
+	}
+	target := strings.TrimSuffix(e.pushURL, "/") + "/metrics/job/" + url.PathEscape(e.job) + "/instance/" + url.PathEscape(instance)
+	var body bytes.Buffer
+	e.write(&body)
+	request, err := http.NewRequest(http.MethodPut, target, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	response, err := (&http.Client{Timeout: 30 * time.Second}).Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", target, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// startTest marks test as the running test
+func (e *prometheusMetrics) startTest(test string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.test, e.step = test, ""
+}
+
+// startStep marks step as the step in progress of the running test
+func (e *prometheusMetrics) startStep(step string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.step = step
+}
+
+// endTest marks that no test is running
+func (e *prometheusMetrics) endTest() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.test, e.step = "", ""
+	return nil
+}
+
+// export adds a step of the running test
+func (e *prometheusMetrics) export(record stepRecord) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.countSteps[record.TestID]++
+	for name, value := range record.Metrics {
+		if e.gauges[name] == nil {
+			e.gauges[name] = map[[2]string]float64{}
+		}
+		e.gauges[name][[2]string{record.TestID, record.Step}] = value
+	}
+
+	countRequests, failureRate, latency, processes := resultDetails(record)
+	if countRequests > 0 {
+		countFailed := math.Round(float64(countRequests) * failureRate)
+		e.requests[[2]string{record.TestID, "success"}] += float64(countRequests) - countFailed
+		e.requests[[2]string{record.TestID, "failure"}] += countFailed
+	}
+	if latency != nil && len(latency.Buckets) == len(model.LatencyBucketBounds) {
+		histogram := e.latency[record.TestID]
+		if histogram == nil {
+			histogram = &latencyHistogram{buckets: make([]uint64, len(model.LatencyBucketBounds))}
+			e.latency[record.TestID] = histogram
+		}
+		countSamples := latency.CountSamples - latency.CountLost
+		for i, count := range latency.Buckets {
+			histogram.buckets[i] += uint64(count)
+		}
+		histogram.count += uint64(countSamples)
+		histogram.sum += latency.Mean.Seconds() * float64(countSamples)
+	}
+	for name, usage := range processes {
+		if usage == nil {
+			continue
+		}
+		e.processCpu[[2]string{record.TestID, name}] = usage.Cpu
+		e.processRam[[2]string{record.TestID, name}] = float64(usage.Ram)
+	}
+	return nil
+}
+
+// write writes the metrics in the Prometheus text exposition format
+func (e *prometheusMetrics) write(w io.Writer) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP npt_run_info The run in progress or last run.\n# TYPE npt_run_info gauge\n")
+	fmt.Fprintf(w, "npt_run_info{run_id=%s} 1\n", quoteLabel(e.runID))
+	fmt.Fprintf(w, "# HELP npt_current_test The running test and step.\n# TYPE npt_current_test gauge\n")
+	if e.test != "" {
+		fmt.Fprintf(w, "npt_current_test{test=%s,step=%s} 1\n", quoteLabel(e.test), quoteLabel(e.step))
+	}
+
+	fmt.Fprintf(w, "# HELP npt_steps_total Steps run by test.\n# TYPE npt_steps_total counter\n")
+	for _, test := range sortedKeys(e.countSteps) {
+		fmt.Fprintf(w, "npt_steps_total{test=%s} %g\n", quoteLabel(test), e.countSteps[test])
+	}
+	fmt.Fprintf(w, "# HELP npt_requests_total Requests sent by test and outcome.\n# TYPE npt_requests_total counter\n")
+	writeSeries(w, "npt_requests_total", "test", "outcome", e.requests)
+
+	fmt.Fprintf(w, "# HELP npt_latency_seconds Latency of successful requests and echoes by test.\n# TYPE npt_latency_seconds histogram\n")
+	for _, test := range sortedKeys(e.latency) {
+		histogram := e.latency[test]
+		for i, bound := range model.LatencyBucketBounds {
+			fmt.Fprintf(w, "npt_latency_seconds_bucket{test=%s,le=\"%g\"} %d\n", quoteLabel(test), bound.Seconds(), histogram.buckets[i])
+		}
+		fmt.Fprintf(w, "npt_latency_seconds_bucket{test=%s,le=\"+Inf\"} %d\n", quoteLabel(test), histogram.count)
+		fmt.Fprintf(w, "npt_latency_seconds_sum{test=%s} %g\n", quoteLabel(test), histogram.sum)
+		fmt.Fprintf(w, "npt_latency_seconds_count{test=%s} %d\n", quoteLabel(test), histogram.count)
+	}
+
+	for _, name := range sortedKeys(e.gauges) {
+		fmt.Fprintf(w, "# HELP npt_%s The %s of the last run of each step.\n# TYPE npt_%s gauge\n", name, name, name)
+		writeSeries(w, "npt_"+name, "test", "step", e.gauges[name])
+	}
+
+	fmt.Fprintf(w, "# HELP npt_process_cpu_ratio CPU usage of monitored processes during the last step of each test.\n# TYPE npt_process_cpu_ratio gauge\n")
+	writeSeries(w, "npt_process_cpu_ratio", "test", "process", e.processCpu)
+	fmt.Fprintf(w, "# HELP npt_process_ram_bytes RAM usage of monitored processes during the last step of each test.\n# TYPE npt_process_ram_bytes gauge\n")
+	writeSeries(w, "npt_process_ram_bytes", "test", "process", e.processRam)
+}
+
+// writeSeries writes a sample of name for each pair of label values, in order
+func writeSeries(w io.Writer, name string, label1 string, label2 string, series map[[2]string]float64) {
+	keys := make([][2]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s=%s,%s=%s} %g\n", name, label1, quoteLabel(key[0]), label2, quoteLabel(key[1]), series[key])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and newlines
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
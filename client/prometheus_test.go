@@ -0,0 +1,73 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+func TestPrometheusPush(t *testing.T) {
+	var method, path, body string
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.EscapedPath(), string(content)
+	}))
+	defer pushgateway.Close()
+
+	metrics := newPrometheusMetrics("20240131T120000Z", pushgateway.URL+"/", "npt")
+	metrics.startTest("http_burst")
+	metrics.startStep("10")
+	metrics.export(stepRecord{TestID: "http_burst", Step: "10", Metrics: map[string]float64{"failure_rate": 0.1},
+		Result: model.BurstTest{CountRequests: 10, FailureRate: 0.1}})
+	// the step in progress is the current step, not the last one that finished
+	metrics.startStep("20")
+	if err := metrics.push(); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	instance, _ := os.Hostname()
+	if instance == "" {
+		instance = "unknown"
+	}
+	if want := "/metrics/job/npt/instance/" + url.PathEscape(instance); method != http.MethodPut || path != want {
+		t.Errorf("pushed with %s %s, want PUT %s", method, path, want)
+	}
+	for _, line := range []string{
+		`npt_run_info{run_id="20240131T120000Z"} 1`,
+		`npt_current_test{test="http_burst",step="20"} 1`,
+		`npt_steps_total{test="http_burst"} 1`,
+		`npt_requests_total{test="http_burst",outcome="failure"} 1`,
+		`npt_requests_total{test="http_burst",outcome="success"} 9`,
+		`npt_failure_rate{test="http_burst",step="10"} 0.1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("pushed metrics do not contain %s:\n%s", line, body)
+		}
+	}
+
+	metrics.endTest()
+	if err := metrics.push(); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if strings.Contains(body, "npt_current_test{") {
+		t.Errorf("pushed a current test after the test ended:\n%s", body)
+	}
+}
+
+func TestPrometheusPushError(t *testing.T) {
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	defer pushgateway.Close()
+
+	err := newPrometheusMetrics("20240131T120000Z", pushgateway.URL, "").push()
+	if err == nil || !strings.Contains(err.Error(), "bad metrics") {
+		t.Errorf("push to a failing Pushgateway returned %v, want its error", err)
+	}
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+// stepRecord is the record of one run of a step of a test, written as a line of the run's JSON Lines file
+type stepRecord struct {
+	RunID      string             `json:"run_id"`
+	Host       string             `json:"host"`            // the host name of the device under test
+	Label      string             `json:"label,omitempty"` // the log_file_postfix of the configuration
+	TestID     string             `json:"test_id"`         // the name of the test in the configuration, e.g. "https_burst"
+	StepID     string             `json:"step_id"`         // unique in the run, <test_id>/<number of the record in the test>
+	Step       string             `json:"step"`            // e.g. the burst size or the rate
+	Repetition int                `json:"repetition"`
+	Timestamp  time.Time          `json:"timestamp"`
+	Metrics    map[string]float64 `json:"metrics"`          // see stepMetric
+	Result     any                `json:"result,omitempty"` // the model struct of the step, e.g. a BurstTest
+}
+
+// resultRecorder records the steps of the running test to the run's JSON Lines file and evaluates the test's assertions
+// once it finishes
+type resultRecorder struct {
+	mutex         sync.Mutex
+	runID         string
+	host          string
+	label         string
+	jsonl         *os.File
+	assertions    map[string][]assertion
+	test          string // the name of the running test in the configuration, e.g. "https_burst"
+	step          string // the step in progress of the running test
+	started       time.Time
+	steps         []stepRecord
+	suites        []junitTestSuite
+	countFailures int
+	tests         []testStatus
+	summaries     []testSummary
+	exporters     []resultExporter
+	stop          chan struct{} // closed to stop the monitoring of the processes, nil if they are not monitored
+	monitoring    sync.WaitGroup
+}
+
+// recorder records the results of the run in progress
+var recorder = &resultRecorder{}
+
+// open starts the run and its JSON Lines file <name>.jsonl. label is the label of the run's records, i.e. its
+// log_file_postfix. The processNames are monitored for the exporters of monitoring samples
+func (e *resultRecorder) open(runID string, label string, name string, exporters []resultExporter, processNames []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.runID = runID
+	e.host, _ = os.Hostname()
+	e.label = label
+	e.exporters = exporters
+	for _, exporter := range exporters {
+		if _, ok := exporter.(sampleExporter); ok && len(processNames) > 0 {
+			e.stop = make(chan struct{})
+			e.monitoring.Add(1)
+			go e.monitor(processNames)
+			break
+		}
+	}
+	filename := testResultsDirectory + name + ".jsonl"
+	f, err := os.Create(filename)
+	if err != nil {
+		slog.Error("Could not create the JSON results, results will not be recorded as JSON", "file", filename, "error", err)
+		return
+	}
+	e.jsonl = f
+}
+
+// close closes the run's JSON Lines file and its exporters
+func (e *resultRecorder) close() {
+	if e.stop != nil {
+		close(e.stop)
+		e.monitoring.Wait()
+		e.stop = nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.jsonl != nil {
+		e.jsonl.Close()
+		e.jsonl = nil
+	}
+	for _, exporter := range e.exporters {
+		if err := exporter.close(); err != nil {
+			slog.Error("Export failed", "exporter", exporter.name(), "error", err)
+		}
+	}
+	e.exporters = nil
+}
+
+// startTest starts recording the steps of test
+func (e *resultRecorder) startTest(test string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.test, e.step = test, ""
+	e.started = time.Now()
+	e.steps = nil
+	for _, exporter := range e.exporters {
+		exporter.startTest(test)
+	}
+}
+
+// startStep marks step as the step of the running test in progress, for the exporters that show it
+func (e *resultRecorder) startStep(step string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.test == "" {
+		return
+	}
+	e.step = step
+	for _, exporter := range e.exporters {
+		if starter, ok := exporter.(stepStarter); ok {
+			starter.startStep(step)
+		}
+	}
+}
+
+// record records the result and metrics of a step of the running test
+func (e *resultRecorder) record(step string, result any, metrics []stepMetric) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.test == "" {
+		return
+	}
+	repetition := 1
+	for _, recorded := range e.steps {
+		if recorded.Step == step {
+			repetition++
+		}
+	}
+	record := stepRecord{
+		RunID:      e.runID,
+		Host:       e.host,
+		Label:      e.label,
+		TestID:     e.test,
+		StepID:     fmt.Sprintf("%s/%d", e.test, len(e.steps)+1),
+		Step:       step,
+		Repetition: repetition,
+		Timestamp:  time.Now().UTC(),
+		Metrics:    map[string]float64{},
+		Result:     result,
+	}
+	for _, metric := range metrics {
+		record.Metrics[metric.name] = metric.value
+	}
+	e.steps = append(e.steps, record)
+	for _, exporter := range e.exporters {
+		if err := exporter.export(record); err != nil {
+			slog.Error("Export of a step failed", "exporter", exporter.name(), "step", record.StepID, "error", err)
+		}
+	}
+
+	if e.jsonl == nil {
+		return
+	}
+	line, err := marshalFinite(record)
+	if err != nil {
+		slog.Error("Could not encode the record of a step", "step", record.StepID, "error", err)
+		return
+	}
+	e.jsonl.Write(append(line, '\n'))
+}
+
+// monitor samples the CPU and RAM usage of the processNames every processInterval while a test runs and exports the
+// samples, until the run is closed
+func (e *resultRecorder) monitor(processNames []string) {
+	defer e.monitoring.Done()
+	ticker := time.NewTicker(processInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+		usage := util.GetCPUandRAMForProcesses(processNames)
+		e.mutex.Lock()
+		if e.test != "" {
+			sample := monitoringSample{
+				RunID:     e.runID,
+				Host:      e.host,
+				Label:     e.label,
+				TestID:    e.test,
+				Step:      e.step,
+				Timestamp: time.Now().UTC(),
+				Processes: model.ProcessCpuAndRam{},
+			}
+			for name, u := range usage {
+				if u != nil && u.ProcessCount > 0 {
+					sample.Processes[name] = u
+				}
+			}
+			for _, exporter := range e.exporters {
+				if sampler, ok := exporter.(sampleExporter); ok {
+					if err := sampler.exportSample(sample); err != nil {
+						slog.Error("Export of a monitoring sample failed", "exporter", exporter.name(), "error", err)
+					}
+				}
+			}
+		}
+		e.mutex.Unlock()
+	}
+}
+
+// endTest finishes the running test, evaluates its assertions and records its status for the manifest and its summary
+func (e *resultRecorder) endTest() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	suite := e.evaluateAssertions()
+	status := "passed"
+	if suite.Failures > 0 {
+		status = "failed"
+	} else if len(e.steps) == 0 {
+		status = "no results"
+	}
+	e.tests = append(e.tests, testStatus{
+		TestID:          e.test,
+		Status:          status,
+		Started:         e.started.UTC(),
+		Ended:           time.Now().UTC(),
+		CountSteps:      len(e.steps),
+		CountAssertions: len(e.assertions[e.test]),
+		CountFailures:   suite.Failures,
+	})
+	e.summaries = append(e.summaries, e.summarize(status))
+	for _, exporter := range e.exporters {
+		if err := exporter.endTest(); err != nil {
+			slog.Error("Export of a test failed", "exporter", exporter.name(), "test", e.test, "error", err)
+		}
+	}
+	e.test = ""
+}
+
+// marshalFinite encodes v as JSON like json.Marshal, but with non-finite numbers, e.g. the NaN failure rate of a step
+// that sent no requests, encoded as null rather than failing
+func marshalFinite(v any) ([]byte, error) {
+	encoded, err := json.Marshal(v)
+	var unsupported *json.UnsupportedValueError
+	if !errors.As(err, &unsupported) {
+		return encoded, err
+	}
+	var buf bytes.Buffer
+	if err := appendFinite(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendFinite appends v encoded as JSON, with non-finite numbers as null
+func appendFinite(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+		buf.WriteString("null")
+		return nil
+	}
+	if v.Type().Implements(reflect.TypeFor[json.Marshaler]()) || v.Type().Implements(reflect.TypeFor[encoding.TextMarshaler]()) {
+		return appendJSON(buf, v.Interface())
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			buf.WriteString("null")
+			return nil
+		}
+	case reflect.Pointer, reflect.Interface:
+		return appendFinite(buf, v.Elem())
+	case reflect.Struct:
+		buf.WriteByte('{')
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" || strings.Contains(options, "omitempty") && isEmptyJSON(v.Field(i)) {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			appendJSON(buf, name)
+			buf.WriteByte(':')
+			if err := appendFinite(buf, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make(map[string]reflect.Value, len(keys))
+		sorted := make([]string, len(keys))
+		for i, key := range keys {
+			sorted[i] = fmt.Sprint(key.Interface())
+			names[sorted[i]] = key
+		}
+		sort.Strings(sorted)
+		buf.WriteByte('{')
+		for i, name := range sorted {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			appendJSON(buf, name)
+			buf.WriteByte(':')
+			if err := appendFinite(buf, v.MapIndex(names[name])); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := appendFinite(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	return appendJSON(buf, v.Interface())
+}
+
+// appendJSON appends v encoded by json.Marshal
+func appendJSON(buf *bytes.Buffer, v any) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(encoded)
+	return nil
+}
+
+// isEmptyJSON returns whether json.Marshal omits v from a field tagged omitempty
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
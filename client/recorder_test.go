@@ -0,0 +1,54 @@
+package client
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+func TestMarshalFinite(t *testing.T) {
+	record := func(failureRate float64) stepRecord {
+		return stepRecord{
+			RunID:     "20240131T120000Z",
+			TestID:    "dns_udp_rate",
+			Step:      "10",
+			Timestamp: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+			Metrics:   map[string]float64{"failure_rate": failureRate, "p99_latency_ms": 8.2},
+			Result: model.RateTest{
+				FailureRate:      failureRate,
+				ProcessCpuAndRam: model.ProcessCpuAndRam{"server": {Cpu: 0.5, ProcessName: "server"}},
+				Failures:         model.FailureClasses{"timeout": 3},
+				Latency:          model.LatencyStats{P99: 8200 * time.Microsecond},
+			},
+		}
+	}
+
+	line, err := marshalFinite(record(math.NaN()))
+	if err != nil {
+		t.Fatalf("marshalFinite of a NaN failure rate failed: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", line, err)
+	}
+
+	// the same as json.Marshal encodes the record, but with null for the NaNs
+	finite, err := marshalFinite(record(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]any
+	json.Unmarshal(finite, &want)
+	want["metrics"].(map[string]any)["failure_rate"] = nil
+	want["result"].(map[string]any)["failure_rate"] = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("marshalFinite = %s, want %s with null failure rates", line, finite)
+	}
+	if standard, _ := json.Marshal(record(0)); string(standard) != string(finite) {
+		t.Errorf("marshalFinite of finite values = %s, want %s", finite, standard)
+	}
+}
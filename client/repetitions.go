@@ -0,0 +1,151 @@
+package client
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+// repetitionRest is the rest between repetitions of a step of a test that has no rest period of its own
+const repetitionRest = 5 * time.Second
+
+// stepMetric is one value measured in a step of a test, e.g. the p99 latency. Names are in snake case ending in the unit,
+// e.g. "p99_latency_ms", and are the names assertions refer to; rates are fractions, not percentages
+type stepMetric struct {
+	name  string
+	value float64
+}
+
+// latencyMetrics returns the latency metrics of a step
+func latencyMetrics(latency model.LatencyStats) []stepMetric {
+	return []stepMetric{
+		{"mean_latency_ms", durationToMilliseconds(latency.Mean)},
+		{"p50_latency_ms", durationToMilliseconds(latency.P50)},
+		{"p95_latency_ms", durationToMilliseconds(latency.P95)},
+		{"p99_latency_ms", durationToMilliseconds(latency.P99)},
+	}
+}
+
+// pingMetrics returns the metrics of a series of echoed samples, e.g. of a ping test
+func pingMetrics(latency model.LatencyStats) []stepMetric {
+	return append(latencyMetrics(latency),
+		stepMetric{"jitter_ms", durationToMilliseconds(latency.Jitter)},
+		stepMetric{"loss_rate", latency.LossRate()})
+}
+
+// burstMetrics returns the metrics of a step of an HTTP or DNS burst test
+func burstMetrics(result model.BurstTest) []stepMetric {
+	return append([]stepMetric{
+		{"time_to_complete_ms", durationToMilliseconds(result.Duration)},
+		{"failure_rate", result.FailureRate},
+	}, latencyMetrics(result.Latency)...)
+}
+
+// rateMetrics returns the metrics of a step of an HTTP, DNS or gRPC rate test
+func rateMetrics(result model.RateTest) []stepMetric {
+	return append([]stepMetric{{"failure_rate", result.FailureRate}}, latencyMetrics(result.Latency)...)
+}
+
+// throughputMetrics returns the metrics of a throughput transfer
+func throughputMetrics(result model.ThroughputTest) []stepMetric {
+	megabitsPerSecond := 0.0
+	if result.DurationNanoseconds > 0 {
+		megabitsPerSecond = float64(result.CountBytesTransferred) * 8 / 1e6 / (float64(result.DurationNanoseconds) / 1e9)
+	}
+	return []stepMetric{{"throughput_mbps", megabitsPerSecond}}
+}
+
+// csvMetric returns the name and value of a metric as the Summary and comparison CSVs report it, in the words and units of
+// the result CSVs, e.g. "p99 latency (ms)", and "failure rate (%)" for failure_rate with the rate in percent
+func csvMetric(metric stepMetric) (string, float64) {
+	switch metric.name {
+	case "failure_rate":
+		return "failure rate (%)", metric.value * 100
+	case "loss_rate":
+		return "loss (%)", metric.value * 100
+	case "throughput_mbps":
+		return "transfer rate (Mb/s)", metric.value
+	}
+	if name, ok := strings.CutSuffix(metric.name, "_ms"); ok {
+		return strings.ReplaceAll(name, "_", " ") + " (ms)", metric.value
+	}
+	return strings.ReplaceAll(metric.name, "_", " "), metric.value
+}
+
+// repetitionSummary collects the metrics of every repetition of each step of a test
+type repetitionSummary struct {
+	steps   []string
+	metrics map[string][]string             // the metric names of each step, in order
+	values  map[string]map[string][]float64 // the values of each metric of each step, one per repetition
+}
+
+func newRepetitionSummary() *repetitionSummary {
+	return &repetitionSummary{metrics: map[string][]string{}, values: map[string]map[string][]float64{}}
+}
+
+// add records the metrics of one repetition of step
+func (e *repetitionSummary) add(step string, metrics []stepMetric) {
+	if _, ok := e.values[step]; !ok {
+		e.steps = append(e.steps, step)
+		e.values[step] = map[string][]float64{}
+	}
+	for _, metric := range metrics {
+		name, value := csvMetric(metric)
+		if _, ok := e.values[step][name]; !ok {
+			e.metrics[step] = append(e.metrics[step], name)
+		}
+		e.values[step][name] = append(e.values[step][name], value)
+	}
+}
+
+// write writes <name>.csv with the mean, standard deviation, median and 95% confidence interval of the mean of every metric
+// of every step, and warns about the metrics whose coefficient of variation is above stabilityThreshold percent
+func (e *repetitionSummary) write(name string, stepHeader string, stabilityThreshold float64) {
+	createLogFile(testResultsDirectory+name+".csv", func(w *csv.Writer) {
+		w.Write([]string{stepHeader, "metric", "repetitions", "mean", "stddev", "median", "95% CI low", "95% CI high", "CV (%)", "stable"})
+		for _, step := range e.steps {
+			for _, metric := range e.metrics[step] {
+				values := e.values[step][metric]
+				mean, stdDev := util.MeanAndStdDev(values)
+				margin := 0.0
+				if len(values) > 1 {
+					margin = util.StudentTQuantile(0.95, float64(len(values)-1)) * stdDev / math.Sqrt(float64(len(values)))
+				}
+				cv, stable := "N/A", true
+				if mean != 0 {
+					cv = fmt.Sprintf("%.2f", stdDev/math.Abs(mean)*100)
+					stable = stdDev/math.Abs(mean)*100 <= stabilityThreshold
+				}
+				if !stable {
+					slog.Warn("Unstable: "+name+" "+step+" "+metric+" varies by "+cv+"%", "repetitions", len(values), "mean", mean, "stddev", stdDev)
+				}
+				w.Write([]string{
+					step, metric, strconv.Itoa(len(values)),
+					fmt.Sprintf("%.3f", mean), fmt.Sprintf("%.3f", stdDev), fmt.Sprintf("%.3f", median(values)),
+					fmt.Sprintf("%.3f", mean-margin), fmt.Sprintf("%.3f", mean+margin),
+					cv, strconv.FormatBool(stable),
+				})
+			}
+		}
+	})
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	if len(sorted)%2 == 0 {
+		return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
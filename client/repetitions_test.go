@@ -0,0 +1,22 @@
+package client
+
+import "testing"
+
+func TestCSVMetric(t *testing.T) {
+	for _, c := range []struct {
+		metric stepMetric
+		name   string
+		value  float64
+	}{
+		{stepMetric{"failure_rate", 0.015}, "failure rate (%)", 1.5},
+		{stepMetric{"loss_rate", 0.5}, "loss (%)", 50},
+		{stepMetric{"throughput_mbps", 940}, "transfer rate (Mb/s)", 940},
+		{stepMetric{"p99_latency_ms", 8.2}, "p99 latency (ms)", 8.2},
+		{stepMetric{"time_to_complete_ms", 31}, "time to complete (ms)", 31},
+		{stepMetric{"messages_per_second", 1000}, "messages per second", 1000},
+	} {
+		if name, value := csvMetric(c.metric); name != c.name || value != c.value {
+			t.Errorf("csvMetric(%v) = %q, %v, want %q, %v", c.metric, name, value, c.name, c.value)
+		}
+	}
+}
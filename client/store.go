@@ -0,0 +1,200 @@
+package client
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	_ "modernc.org/sqlite" // registers the pure Go "sqlite" driver
+)
+
+// defaultStorePath is the SQLite database of every run, next to the run directories
+const defaultStorePath = "test-results/results.db"
+
+// storeTimeFormat is the format of the times of the store, RFC 3339 in UTC with a fixed number of digits so that they
+// sort as they compare
+const storeTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// storeSchema creates the tables of the store
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	run_id        TEXT PRIMARY KEY,
+	host          TEXT NOT NULL,
+	label         TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	started       TEXT NOT NULL,
+	ended         TEXT,
+	tool_version  TEXT,
+	git_commit    TEXT,
+	config_sha256 TEXT
+);
+CREATE TABLE IF NOT EXISTS devices (
+	run_id                      TEXT PRIMARY KEY REFERENCES runs(run_id),
+	cpu_model_name              TEXT,
+	cpu_core_count              INTEGER,
+	cpu_base_clock_frequency_hz INTEGER,
+	ram_total_bytes             INTEGER
+);
+CREATE TABLE IF NOT EXISTS tests (
+	run_id           TEXT NOT NULL REFERENCES runs(run_id),
+	test_id          TEXT NOT NULL,
+	status           TEXT NOT NULL,
+	started          TEXT NOT NULL,
+	ended            TEXT NOT NULL,
+	count_steps      INTEGER NOT NULL,
+	count_assertions INTEGER NOT NULL,
+	count_failures   INTEGER NOT NULL,
+	PRIMARY KEY (run_id, test_id)
+);
+CREATE TABLE IF NOT EXISTS steps (
+	run_id     TEXT NOT NULL REFERENCES runs(run_id),
+	step_id    TEXT NOT NULL,
+	test_id    TEXT NOT NULL,
+	step       TEXT NOT NULL,
+	repetition INTEGER NOT NULL,
+	timestamp  TEXT NOT NULL,
+	result     TEXT, -- the JSON of the model struct of the step
+	PRIMARY KEY (run_id, step_id)
+);
+CREATE TABLE IF NOT EXISTS metrics (
+	run_id  TEXT NOT NULL,
+	step_id TEXT NOT NULL,
+	name    TEXT NOT NULL,
+	value   REAL NOT NULL,
+	PRIMARY KEY (run_id, step_id, name),
+	FOREIGN KEY (run_id, step_id) REFERENCES steps(run_id, step_id)
+);
+CREATE INDEX IF NOT EXISTS metrics_by_name ON metrics(name);
+CREATE TABLE IF NOT EXISTS samples (
+	run_id   TEXT NOT NULL,
+	step_id  TEXT NOT NULL,
+	sequence INTEGER NOT NULL,
+	rtt_ms   REAL, -- NULL if the sample was lost
+	lost     INTEGER NOT NULL,
+	PRIMARY KEY (run_id, step_id, sequence),
+	FOREIGN KEY (run_id, step_id) REFERENCES steps(run_id, step_id)
+);
+`
+
+// resultStore records the run, its tests, steps, metrics and raw samples in a SQLite database shared by every run, so
+// that the history of a metric can be queried across runs
+type resultStore struct {
+	db    *sql.DB
+	path  string
+	runID string
+}
+
+// openStore opens or creates the database at path and records the start of the run
+func openStore(path string, run *manifest, label string) (*resultStore, error) {
+	if path == "" {
+		path = defaultStorePath
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// the busy timeout lets runs on the same machine share the database
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create the tables of %s: %v", path, err)
+	}
+	host, _ := os.Hostname() // as in the run's records
+	_, err = db.Exec(`INSERT INTO runs (run_id, host, label, status, started, tool_version, git_commit, config_sha256) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.RunID, host, label, "running", run.Started.UTC().Format(storeTimeFormat), run.ToolVersion, run.GitCommit, run.ConfigSHA256)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not record run %s in %s: %v", run.RunID, path, err)
+	}
+	return &resultStore{db: db, path: path, runID: run.RunID}, nil
+}
+
+func (e *resultStore) name() string {
+	return "SQLite store"
+}
+
+func (e *resultStore) startTest(test string) {}
+
+// export records a step with its finite metrics and, for ping tests, its samples. Non-finite values in its result are
+// recorded as null
+func (e *resultStore) export(record stepRecord) error {
+	result, err := marshalFinite(record.Result)
+	if err != nil {
+		return err
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO steps (run_id, step_id, test_id, step, repetition, timestamp, result) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		record.RunID, record.StepID, record.TestID, record.Step, record.Repetition, record.Timestamp.UTC().Format(storeTimeFormat), string(result))
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(record.Metrics) {
+		// SQLite stores a NaN as NULL, which the value cannot be, so a non-finite value, e.g. the failure rate of a step
+		// that sent no requests, is left out like an unknown one
+		if math.IsNaN(record.Metrics[name]) || math.IsInf(record.Metrics[name], 0) {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO metrics (run_id, step_id, name, value) VALUES (?, ?, ?, ?)`, record.RunID, record.StepID, name, record.Metrics[name]); err != nil {
+			return err
+		}
+	}
+	if ping, ok := record.Result.(model.PingTest); ok {
+		for _, sample := range ping.Samples {
+			var rtt any
+			if !sample.Lost {
+				rtt = float64(sample.RTT) / float64(time.Millisecond)
+			}
+			if _, err := tx.Exec(`INSERT INTO samples (run_id, step_id, sequence, rtt_ms, lost) VALUES (?, ?, ?, ?, ?)`, record.RunID, record.StepID, sample.Sequence, rtt, sample.Lost); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (e *resultStore) endTest() error {
+	return nil
+}
+
+// close does nothing, the run is finished by finish once its status is known
+func (e *resultStore) close() error {
+	return nil
+}
+
+// finish records the status and tests of the run and the device it ran on, and closes the database
+func (e *resultStore) finish(run *manifest) error {
+	defer e.db.Close()
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE runs SET status = ?, ended = ? WHERE run_id = ?`, run.Status, run.Ended.UTC().Format(storeTimeFormat), e.runID); err != nil {
+		return err
+	}
+	if device := run.Device; device != nil {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO devices (run_id, cpu_model_name, cpu_core_count, cpu_base_clock_frequency_hz, ram_total_bytes) VALUES (?, ?, ?, ?, ?)`,
+			e.runID, device.CPU_ModelName, device.CPU_CoreCount, device.CPU_BaseClockFrequency, device.RAM_Total)
+		if err != nil {
+			return err
+		}
+	}
+	for _, test := range run.Tests {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO tests (run_id, test_id, status, started, ended, count_steps, count_assertions, count_failures) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.runID, test.TestID, test.Status, test.Started.UTC().Format(storeTimeFormat), test.Ended.UTC().Format(storeTimeFormat), test.CountSteps, test.CountAssertions, test.CountFailures)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
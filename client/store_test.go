@@ -0,0 +1,78 @@
+package client
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/history"
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+func TestStoreHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	host, _ := os.Hostname()
+	started := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	for i, p99 := range []float64{8, 10} {
+		run := &manifest{RunID: started.Add(time.Duration(i) * time.Hour).Format("20060102T150405Z"), Started: started.Add(time.Duration(i) * time.Hour)}
+		store, err := openStore(path, run, "wifi")
+		if err != nil {
+			t.Fatalf("openStore failed: %v", err)
+		}
+		steps := []stepRecord{
+			// a step that sent no requests has a NaN failure rate
+			{TestID: "dns_udp_rate", StepID: "dns_udp_rate/1", Step: "0", Metrics: map[string]float64{"failure_rate": math.NaN(), "p99_latency_ms": p99},
+				Result: model.RateTest{FailureRate: math.NaN()}},
+			{TestID: "ping", StepID: "ping/1", Step: "2", Metrics: map[string]float64{"p99_latency_ms": p99},
+				Result: model.PingTest{Samples: []model.PingSample{{Sequence: 0, RTT: time.Millisecond}, {Sequence: 1, Lost: true}}}},
+		}
+		for _, record := range steps {
+			record.RunID, record.Host, record.Repetition, record.Timestamp = run.RunID, host, 1, run.Started
+			if err := store.export(record); err != nil {
+				t.Fatalf("export of step %s failed: %v", record.StepID, err)
+			}
+		}
+		run.Status, run.Ended = "passed", run.Started.Add(time.Minute)
+		run.Tests = []testStatus{{TestID: "dns_udp_rate", Status: "passed", Started: run.Started, Ended: run.Ended, CountSteps: 1}}
+		if err := store.finish(run); err != nil {
+			t.Fatalf("finish failed: %v", err)
+		}
+	}
+
+	output, code := runHistory(t, "-db", path, "-metric", "p99_latency_ms", "-label", "wifi", "-test", "dns_udp_rate")
+	if code != 0 {
+		t.Fatalf("history exited with %d:\n%s", code, output)
+	}
+	for _, want := range []string{"dns_udp_rate step 0 p99_latency_ms", "20240131T120000Z", "20240131T130000Z", "8.000", "10.000", "+25.00%"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("history does not show %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "ping") {
+		t.Errorf("history shows another test:\n%s", output)
+	}
+	// the NaN failure rates were left out rather than failing the steps
+	if output, code := runHistory(t, "-db", path, "-metric", "failure_rate"); code != 1 {
+		t.Errorf("history of the NaN failure rates exited with %d, want 1:\n%s", code, output)
+	}
+}
+
+// runHistory runs the history command with args and returns its standard output and exit code
+func runHistory(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	code := history.Run(args)
+	os.Stdout = stdout
+	w.Close()
+	output, _ := io.ReadAll(r)
+	return string(output), code
+}
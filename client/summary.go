@@ -0,0 +1,121 @@
+package client
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// sustainableFailureRate is the highest failure rate at which a request rate counts as sustained
+const sustainableFailureRate = 0.01
+
+// testSummary holds the key metrics of a test over all of its steps, NaN where the test has no such metric
+type testSummary struct {
+	test           string
+	status         string
+	countSteps     int
+	peakThroughput float64 // Mbit/s, the highest of any step
+	p99Latency     float64 // ms, the highest of any step
+	sustainedRate  float64 // requests per second, the highest rate whose failure rate was at most sustainableFailureRate, below any rate whose failure rate was higher
+	failureRate    float64 // the highest failure or loss rate of any step
+}
+
+// summarize summarizes the steps of the running test
+func (e *resultRecorder) summarize(status string) testSummary {
+	summary := testSummary{test: e.test, status: status, countSteps: len(e.steps), peakThroughput: math.NaN(), p99Latency: math.NaN(), sustainedRate: math.NaN(), failureRate: math.NaN()}
+	highest := func(current float64, value float64) float64 {
+		if math.IsNaN(current) || value > current {
+			return value
+		}
+		return current
+	}
+	var sustainedRates []float64
+	lowestFailedRate := math.Inf(1)
+	for _, step := range e.steps {
+		if value, ok := step.Metrics["throughput_mbps"]; ok {
+			summary.peakThroughput = highest(summary.peakThroughput, value)
+		}
+		if value, ok := step.Metrics["p99_latency_ms"]; ok {
+			summary.p99Latency = highest(summary.p99Latency, value)
+		}
+		failureRate, ok := step.Metrics["failure_rate"]
+		if !ok {
+			failureRate, ok = step.Metrics["loss_rate"]
+		}
+		if !ok {
+			continue
+		}
+		summary.failureRate = highest(summary.failureRate, failureRate)
+		// the step of a rate test is its rate, e.g. "100" or "unary 100" for gRPC
+		fields := strings.Fields(step.Step)
+		if len(fields) == 0 || !strings.HasSuffix(e.test, "_rate") && fields[0] != "unary" {
+			continue
+		}
+		if rate, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+			if failureRate <= sustainableFailureRate {
+				sustainedRates = append(sustainedRates, float64(rate))
+			} else {
+				lowestFailedRate = min(lowestFailedRate, float64(rate))
+			}
+		}
+	}
+	// a rate only counts as sustained if no lower rate failed, as the test would have stopped there
+	for _, rate := range sustainedRates {
+		if rate < lowestFailedRate {
+			summary.sustainedRate = highest(summary.sustainedRate, rate)
+		}
+	}
+	if math.IsNaN(summary.sustainedRate) && !math.IsInf(lowestFailedRate, 1) {
+		summary.sustainedRate = 0
+	}
+	return summary
+}
+
+var summaryHeader = []string{"test", "status", "steps", "peak throughput (Mbit/s)", "p99 latency (ms)", "max sustainable rate (req/s)", "failure rate (%)"}
+
+func (e testSummary) cells() []string {
+	format := func(value float64, scale float64, precision int) string {
+		if math.IsNaN(value) {
+			return "-"
+		}
+		return strconv.FormatFloat(value*scale, 'f', precision, 64)
+	}
+	return []string{e.test, e.status, strconv.Itoa(e.countSteps), format(e.peakThroughput, 1, 1), format(e.p99Latency, 1, 3), format(e.sustainedRate, 1, 0), format(e.failureRate, 100, 2)}
+}
+
+// writeSummary prints a table of the key metrics of every test that ran and writes it as Markdown to <name>.md
+func (e *resultRecorder) writeSummary(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.summaries) == 0 {
+		return
+	}
+	fmt.Printf("\nSummary of run %s:\n", e.runID)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(summaryHeader, "\t"))
+	for _, summary := range e.summaries {
+		fmt.Fprintln(w, strings.Join(summary.cells(), "\t"))
+	}
+	w.Flush()
+	fmt.Println()
+
+	var markdown strings.Builder
+	fmt.Fprintf(&markdown, "### Run %s", e.runID)
+	if e.label != "" {
+		fmt.Fprintf(&markdown, " (%s)", e.label)
+	}
+	fmt.Fprintf(&markdown, " on %s\n\n", e.host)
+	fmt.Fprintf(&markdown, "| %s |\n|---|---|%s\n", strings.Join(summaryHeader, " | "), strings.Repeat("---:|", len(summaryHeader)-2))
+	for _, summary := range e.summaries {
+		fmt.Fprintf(&markdown, "| %s |\n", strings.Join(summary.cells(), " | "))
+	}
+	fmt.Fprintf(&markdown, "\nThe maximum sustainable rate is the highest rate with at most %.0f%% failed requests, below the lowest rate with more.\n", sustainableFailureRate*100)
+	filename := testResultsDirectory + name + ".md"
+	if err := os.WriteFile(filename, []byte(markdown.String()), 0644); err != nil {
+		slog.Error("Could not write the summary", "file", filename, "error", err)
+	}
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSummarizeSustainedRate(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		failureRates map[string][]float64 // by rate, of each repetition
+		want         float64
+	}{
+		{"all passed", map[string][]float64{"100": {0}, "200": {0.01}}, 200},
+		{"a higher rate passed after a failure", map[string][]float64{"100": {0}, "200": {0}, "300": {0.5}, "400": {0}}, 200},
+		{"a repetition failed", map[string][]float64{"100": {0}, "200": {0, 0.02}, "300": {0}}, 100},
+		{"no requests", map[string][]float64{"100": {0}, "200": {math.NaN()}}, 100},
+		{"all failed", map[string][]float64{"100": {0.5}}, 0},
+	} {
+		recorder := &resultRecorder{test: "http_rate"}
+		for _, rate := range []string{"100", "200", "300", "400"} {
+			for _, failureRate := range c.failureRates[rate] {
+				recorder.steps = append(recorder.steps, stepRecord{Step: rate, Metrics: map[string]float64{"failure_rate": failureRate}})
+			}
+		}
+		if got := recorder.summarize("passed").sustainedRate; got != c.want {
+			t.Errorf("%s: sustained rate %g, want %g", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package compare
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"gopkg.in/yaml.v2"
+)
+
+// runPrefix matches the timestamp that starts the name of every result file of a run, e.g. "20240131T120000Z"
+var runPrefix = regexp.MustCompile(`^\d{8}T\d{6}Z`)
+
+// stepHeaders are the CSV columns that identify the step of a row rather than measure it
+var stepHeaders = map[string]bool{
+	"number of http requests in burst": true,
+	"number of requests in burst":      true,
+	"requests per second":              true,
+	"calls per second":                 true,
+	"message size (B)":                 true,
+	"transfer mode (half/full duplex)": true,
+	"probe":                            true,
+	"port":                             true,
+	"transport":                        true,
+	"query type":                       true,
+	"requested response size (B)":      true,
+	"Process Name":                     true,
+	"metric":                           true, // repetition summaries have a row per step and metric
+}
+
+// measurement identifies one value of a run
+type measurement struct {
+	test   string // e.g. "-httpsBurstTest-filtered"
+	step   string // e.g. "10", or "-" for tests with a single row
+	metric string // the CSV column, e.g. "p99 latency (ms)"
+}
+
+// delta is the change of one measurement from the baseline runs to the candidate runs
+type delta struct {
+	measurement
+	baseline  []float64
+	candidate []float64
+	change    float64 // percent, ±Inf if the baseline is 0
+	p         float64
+	pValid    bool
+	threshold float64
+	verdict   string // "REGRESSION", "improvement", insufficientData or empty
+}
+
+// Run runs the compare command with args and returns its exit code: 0 if no regression was found, 1 if one was and 2 on error
+func Run(args []string) int {
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	thresholdsFile := flags.String("thresholds", "", "YAML file of regression thresholds")
+	threshold := flags.Float64("threshold", 0, "default regression threshold in percent, overriding the thresholds file")
+	alpha := flags.Float64("alpha", 0, "significance level when there are several runs per side, overriding the thresholds file")
+	countBaseline := flags.Int("baseline", 1, "number of leading runs that form the baseline; the other runs are the candidate")
+	out := flags.String("out", "", "CSV file to export every delta to")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s compare [flags] baseline-run candidate-run [run...]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(flags.Output(), "A run is a directory of result CSVs or the common prefix of their paths, e.g. test-results/20240131T120000Z\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	runs := flags.Args()
+	if len(runs) < 2 || *countBaseline < 1 || *countBaseline >= len(runs) {
+		flags.Usage()
+		return 2
+	}
+
+	thresholds := types.CompareThresholds{}
+	if *thresholdsFile != "" {
+		contents, err := os.ReadFile(*thresholdsFile)
+		if err == nil {
+			err = yaml.Unmarshal(contents, &thresholds)
+		}
+		if err != nil {
+			slog.Error("Could not read thresholds", "file", *thresholdsFile, "error", err)
+			return 2
+		}
+	}
+	if *threshold != 0 {
+		thresholds.Default = *threshold
+	}
+	if thresholds.Default == 0 {
+		thresholds.Default = 10
+	}
+	if *alpha != 0 {
+		thresholds.Alpha = *alpha
+	}
+	if thresholds.Alpha == 0 {
+		thresholds.Alpha = 0.05
+	}
+
+	// every measurement of any run, in the order of the first run that has it
+	var order []measurement
+	seen := map[measurement]bool{}
+	values := make([]map[measurement]float64, len(runs))
+	for i, run := range runs {
+		var err error
+		var runOrder []measurement
+		values[i], runOrder, err = loadRun(run)
+		if err != nil {
+			slog.Error("Could not load a run", "error", err)
+			return 2
+		}
+		for _, key := range runOrder {
+			if !seen[key] {
+				seen[key] = true
+				order = append(order, key)
+			}
+		}
+	}
+
+	deltas, countMissingCandidate, countMissingBaseline := compareRuns(order, values[:*countBaseline], values[*countBaseline:], thresholds)
+	printDeltas(deltas)
+	if countMissingCandidate > 0 {
+		slog.Warn(fmt.Sprintf("%d baseline values have no candidate value to compare with", countMissingCandidate))
+	}
+	if countMissingBaseline > 0 {
+		slog.Warn(fmt.Sprintf("%d candidate values have no baseline value to compare with", countMissingBaseline))
+	}
+	countInsufficient := 0
+	for _, d := range deltas {
+		if d.verdict == insufficientData {
+			countInsufficient++
+		}
+	}
+	if countInsufficient > 0 {
+		slog.Warn(fmt.Sprintf("%d values are missing from too many runs to test whether their change is significant", countInsufficient))
+	}
+	if *out != "" {
+		if err := writeDeltas(*out, deltas); err != nil {
+			slog.Error("Could not write the comparison", "file", *out, "error", err)
+			return 2
+		}
+	}
+
+	countRegressions := 0
+	for _, d := range deltas {
+		if d.verdict == "REGRESSION" {
+			countRegressions++
+			slog.Error(fmt.Sprintf("Regression: %s %s %s %s", d.test, d.step, d.metric, formatChange(d.change)))
+		}
+	}
+	if countRegressions > 0 {
+		slog.Error(fmt.Sprintf("%d regressions found", countRegressions))
+		return 1
+	}
+	slog.Info("No regressions found")
+	return 0
+}
+
+// runFiles returns the result CSVs of the run at path, a directory holding a single run or the prefix of its files
+func runFiles(path string) ([]string, error) {
+	var files []string
+	var err error
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.csv"))
+	} else {
+		files, err = filepath.Glob(path + "*.csv")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runFiles []string
+	prefixes := map[string]bool{}
+	for _, file := range files {
+		// other CSVs, e.g. an exported comparison, are not results
+		if prefix := runPrefix.FindString(filepath.Base(file)); prefix != "" {
+			runFiles = append(runFiles, file)
+			prefixes[prefix] = true
+		}
+	}
+	if len(runFiles) == 0 {
+		return nil, fmt.Errorf("no results found for run %s", path)
+	}
+	if len(prefixes) > 1 {
+		return nil, fmt.Errorf("%s holds %d runs, give the prefix of one of them, e.g. %s", path, len(prefixes), filepath.Join(path, runPrefix.FindString(filepath.Base(runFiles[0]))))
+	}
+	return runFiles, nil
+}
+
+// loadRun reads every numeric value of the run at path, and returns their measurements in file order
+func loadRun(path string) (map[measurement]float64, []measurement, error) {
+	files, err := runFiles(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := map[measurement]float64{}
+	var order []measurement
+	for _, file := range files {
+		test := runPrefix.ReplaceAllString(strings.TrimSuffix(filepath.Base(file), ".csv"), "")
+		if strings.HasSuffix(test, "Samples") || strings.Contains(test, "Comparison") {
+			// raw samples are summarised by their test, and comparison reports hold two paths of a single run
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader := csv.NewReader(f)
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read %s: %v", file, err)
+		}
+		if len(records) < 2 {
+			continue
+		}
+		header := records[0]
+		steps := map[string]bool{}
+		for _, record := range records[1:] {
+			var stepValues []string
+			for i, value := range record {
+				if i < len(header) && stepHeaders[header[i]] {
+					stepValues = append(stepValues, value)
+				}
+			}
+			step := strings.Join(stepValues, "/")
+			if step == "" {
+				step = "-"
+			}
+			if steps[step] {
+				// a repeated step, e.g. a second test with the same burst size
+				for n := 2; ; n++ {
+					if !steps[fmt.Sprintf("%s #%d", step, n)] {
+						step = fmt.Sprintf("%s #%d", step, n)
+						break
+					}
+				}
+			}
+			steps[step] = true
+			for i, cell := range record {
+				if i >= len(header) || stepHeaders[header[i]] {
+					continue
+				}
+				value, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+				if err != nil || math.IsNaN(value) {
+					continue
+				}
+				key := measurement{test, step, header[i]}
+				values[key] = value
+				order = append(order, key)
+			}
+		}
+	}
+	return values, order, nil
+}
+
+// insufficientData is the verdict of a measurement whose change cannot be tested for significance although there are
+// several runs per side, because too few of them have it
+const insufficientData = "insufficient data"
+
+// compareRuns compares the mean of every measurement over the baseline runs with its mean over the candidate runs,
+// and returns the number of measurements that no candidate run has and that no baseline run has
+func compareRuns(order []measurement, baselineRuns []map[measurement]float64, candidateRuns []map[measurement]float64, thresholds types.CompareThresholds) ([]delta, int, int) {
+	var deltas []delta
+	countMissingCandidate, countMissingBaseline := 0, 0
+	// with a single run on either side the change is only compared with the threshold
+	tested := len(baselineRuns) >= 2 && len(candidateRuns) >= 2
+	for _, key := range order {
+		d := delta{measurement: key}
+		for _, run := range baselineRuns {
+			if value, ok := run[key]; ok {
+				d.baseline = append(d.baseline, value)
+			}
+		}
+		for _, run := range candidateRuns {
+			if value, ok := run[key]; ok {
+				d.candidate = append(d.candidate, value)
+			}
+		}
+		if len(d.candidate) == 0 {
+			countMissingCandidate++
+			continue
+		}
+		if len(d.baseline) == 0 {
+			countMissingBaseline++
+			continue
+		}
+		baselineMean, _ := util.MeanAndStdDev(d.baseline)
+		candidateMean, _ := util.MeanAndStdDev(d.candidate)
+		switch {
+		case baselineMean != 0:
+			d.change = (candidateMean - baselineMean) / math.Abs(baselineMean) * 100
+		case candidateMean != 0:
+			d.change = math.Inf(int(math.Copysign(1, candidateMean)))
+		}
+		d.p, d.pValid = util.WelchTTest(d.baseline, d.candidate)
+		d.threshold = metricThreshold(thresholds, key)
+
+		direction := metricDirection(key.metric)
+		switch {
+		case direction == 0:
+		case tested && !d.pValid:
+			d.verdict = insufficientData
+		case tested && d.p >= thresholds.Alpha:
+		case d.change*float64(direction) > d.threshold:
+			d.verdict = "REGRESSION"
+		case -d.change*float64(direction) > d.threshold:
+			d.verdict = "improvement"
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas, countMissingCandidate, countMissingBaseline
+}
+
+// metricThreshold returns the regression threshold in percent of a measurement, the most specific one configured
+func metricThreshold(thresholds types.CompareThresholds, key measurement) float64 {
+	if threshold, ok := thresholds.Tests[key.test][key.metric]; ok {
+		return threshold
+	}
+	for test, metrics := range thresholds.Tests {
+		// a test name without the log file postfix
+		if strings.HasPrefix(key.test, test+"-") {
+			if threshold, ok := metrics[key.metric]; ok {
+				return threshold
+			}
+		}
+	}
+	if threshold, ok := thresholds.Metrics[key.metric]; ok {
+		return threshold
+	}
+	return thresholds.Default
+}
+
+// metricDirection returns 1 if an increase of the metric is worse, -1 if it is better and 0 if it is neither, e.g. a count of samples
+func metricDirection(metric string) int {
+	metric = strings.ToLower(metric)
+	for _, better := range []string{"transfer rate", "messages per second", "messages received", "reused connections"} {
+		if strings.Contains(metric, better) {
+			return -1
+		}
+	}
+	for _, worse := range []string{"latency", "(ms)", "failure rate", "loss", "lost", "jitter", "ipdv", "fallback", "cpu", "ram", "truncated", "new connections"} {
+		if strings.Contains(metric, worse) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func formatChange(change float64) string {
+	if math.IsInf(change, 0) {
+		return fmt.Sprintf("%+.0f%%", change)
+	}
+	return fmt.Sprintf("%+.2f%%", change)
+}
+
+func formatValues(values []float64) string {
+	mean, stdDev := util.MeanAndStdDev(values)
+	if len(values) < 2 {
+		return fmt.Sprintf("%.3f", mean)
+	}
+	return fmt.Sprintf("%.3f±%.3f", mean, stdDev)
+}
+
+func formatP(d delta) string {
+	if !d.pValid {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.4f", d.p)
+}
+
+// printDeltas prints the deltas of the metrics that have a better and a worse direction, grouped by test
+func printDeltas(deltas []delta) {
+	sorted := append([]delta{}, deltas...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].test < sorted[j].test })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "test\tstep\tmetric\tbaseline\tcandidate\tchange\tp-value\tthreshold\tverdict")
+	for _, d := range sorted {
+		if metricDirection(d.metric) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.0f%%\t%s\n", d.test, d.step, d.metric, formatValues(d.baseline), formatValues(d.candidate), formatChange(d.change), formatP(d), d.threshold, d.verdict)
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+// writeDeltas exports every delta to the CSV file at path
+func writeDeltas(path string, deltas []delta) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"test", "step", "metric", "baseline runs", "baseline mean", "baseline stddev", "candidate runs", "candidate mean", "candidate stddev", "change (%)", "p-value", "threshold (%)", "verdict"})
+	for _, d := range deltas {
+		baselineMean, baselineStdDev := util.MeanAndStdDev(d.baseline)
+		candidateMean, candidateStdDev := util.MeanAndStdDev(d.candidate)
+		w.Write([]string{
+			d.test, d.step, d.metric,
+			strconv.Itoa(len(d.baseline)), fmt.Sprintf("%.3f", baselineMean), fmt.Sprintf("%.3f", baselineStdDev),
+			strconv.Itoa(len(d.candidate)), fmt.Sprintf("%.3f", candidateMean), fmt.Sprintf("%.3f", candidateStdDev),
+			fmt.Sprintf("%.2f", d.change), formatP(d), fmt.Sprintf("%.2f", d.threshold), d.verdict,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,46 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+)
+
+func TestCompareRuns(t *testing.T) {
+	latency := measurement{"-httpsBurstTest", "10", "p99 latency (ms)"}
+	rate := measurement{"-httpsThroughputTest", "download", "transfer rate (Mb/s)"}
+	sparse := measurement{"-pingTest", "-", "jitter (ms)"}
+	thresholds := types.CompareThresholds{Default: 10, Alpha: 0.05}
+
+	baseline := []map[measurement]float64{
+		{latency: 10, rate: 900, sparse: 1},
+		{latency: 11, rate: 910},
+	}
+	candidate := []map[measurement]float64{
+		{latency: 20, rate: 905, sparse: 5},
+		{latency: 21, rate: 895},
+	}
+	deltas, countMissingCandidate, countMissingBaseline := compareRuns([]measurement{latency, rate, sparse}, baseline, candidate, thresholds)
+	if countMissingCandidate != 0 || countMissingBaseline != 0 || len(deltas) != 3 {
+		t.Fatalf("%d deltas with %d and %d missing, want 3 with none missing", len(deltas), countMissingCandidate, countMissingBaseline)
+	}
+	for i, want := range []string{"REGRESSION", "", insufficientData} {
+		if deltas[i].verdict != want {
+			t.Errorf("verdict of %s = %q, want %q", deltas[i].metric, deltas[i].verdict, want)
+		}
+	}
+
+	// a single run per side is only compared with the threshold
+	deltas, _, _ = compareRuns([]measurement{latency, sparse}, baseline[:1], candidate[:1], thresholds)
+	if deltas[0].verdict != "REGRESSION" || deltas[1].verdict != "REGRESSION" {
+		t.Errorf("verdicts of single runs = %q, %q, want REGRESSION", deltas[0].verdict, deltas[1].verdict)
+	}
+
+	// a measurement only the candidate has is counted rather than compared
+	added := measurement{"-grpcUnaryRateTest", "100", "p99 latency (ms)"}
+	candidate[1][added] = 3
+	deltas, countMissingCandidate, countMissingBaseline = compareRuns([]measurement{latency, added}, baseline, candidate, thresholds)
+	if len(deltas) != 1 || countMissingCandidate != 0 || countMissingBaseline != 1 {
+		t.Errorf("%d deltas with %d and %d missing, want 1 with a baseline missing", len(deltas), countMissingCandidate, countMissingBaseline)
+	}
+}
@@ -0,0 +1,174 @@
+package history
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	_ "modernc.org/sqlite" // registers the pure Go "sqlite" driver
+)
+
+// point is the value of a metric of one step in one run, the mean over the step's repetitions
+type point struct {
+	runID      string
+	status     string
+	label      string
+	value      float64
+	countSteps int
+}
+
+// series is the history of a metric of one step of a test
+type series struct {
+	test   string
+	step   string
+	points []point
+}
+
+// Run runs the history command with args and returns its exit code: 0 if values were found, 1 if none were and 2 on
+// error
+func Run(args []string) int {
+	hostname, _ := os.Hostname()
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	database := flags.String("db", "test-results/results.db", "SQLite store of the runs")
+	metric := flags.String("metric", "", "metric to show, e.g. p99_latency_ms")
+	dut := flags.String("dut", hostname, "host name of the device under test")
+	label := flags.String("label", "", "log_file_postfix of the runs, any if not given")
+	test := flags.String("test", "", "name of the test in the configuration, e.g. https_burst, any if empty")
+	step := flags.String("step", "", "step of the test, e.g. the burst size, any if empty")
+	limit := flags.Int("limit", 20, "number of most recent runs to show")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s history -metric name [flags]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(flags.Output(), "Shows the trend of a metric over the runs recorded in the store, oldest first\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *metric == "" || flags.NArg() > 0 || *limit < 1 {
+		flags.Usage()
+		return 2
+	}
+	labelGiven := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "label" {
+			labelGiven = true
+		}
+	})
+
+	if _, err := os.Stat(*database); err != nil {
+		slog.Error("Could not open the store", "error", err)
+		return 2
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(*database)+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		slog.Error("Could not open the store", "error", err)
+		return 2
+	}
+	defer db.Close()
+
+	runFilter := []string{"host = ?"}
+	filterArgs := []any{*dut}
+	if labelGiven {
+		runFilter = append(runFilter, "label = ?")
+		filterArgs = append(filterArgs, *label)
+	}
+	query := `
+SELECT r.run_id, r.status, r.label, s.test_id, s.step, AVG(m.value), COUNT(*)
+FROM metrics m
+JOIN steps s ON s.run_id = m.run_id AND s.step_id = m.step_id
+JOIN runs r ON r.run_id = m.run_id
+WHERE m.name = ? AND r.run_id IN (SELECT run_id FROM runs WHERE ` + strings.Join(runFilter, " AND ") + ` ORDER BY started DESC LIMIT ?)`
+	queryArgs := append([]any{*metric}, filterArgs...)
+	queryArgs = append(queryArgs, *limit)
+	if *test != "" {
+		query += " AND s.test_id = ?"
+		queryArgs = append(queryArgs, *test)
+	}
+	if *step != "" {
+		query += " AND s.step = ?"
+		queryArgs = append(queryArgs, *step)
+	}
+	query += `
+GROUP BY r.run_id, s.test_id, s.step
+ORDER BY r.started, MIN(s.timestamp)`
+
+	all, err := loadSeries(db, query, queryArgs)
+	if err != nil {
+		slog.Error("Could not query the store", "error", err)
+		return 2
+	}
+	if len(all) == 0 {
+		slog.Warn(fmt.Sprintf("No values of %s found for %s", *metric, *dut))
+		return 1
+	}
+	for _, s := range all {
+		printSeries(*metric, s)
+	}
+	return 0
+}
+
+// loadSeries runs query and groups its rows by test and step, in the order the series were first run
+func loadSeries(db *sql.DB, query string, args []any) ([]*series, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all []*series
+	byKey := map[[2]string]*series{}
+	for rows.Next() {
+		var test, step string
+		var p point
+		if err := rows.Scan(&p.runID, &p.status, &p.label, &test, &step, &p.value, &p.countSteps); err != nil {
+			return nil, err
+		}
+		s := byKey[[2]string{test, step}]
+		if s == nil {
+			s = &series{test: test, step: step}
+			byKey[[2]string{test, step}] = s
+			all = append(all, s)
+		}
+		s.points = append(s.points, p)
+	}
+	return all, rows.Err()
+}
+
+// printSeries prints the value of the metric in each run of a series with its change from the previous run
+func printSeries(metric string, s *series) {
+	values := make([]float64, len(s.points))
+	for i, p := range s.points {
+		values[i] = p.value
+	}
+	fmt.Printf("%s step %s %s  %s\n", s.test, s.step, metric, util.Sparkline(values))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "run\tlabel\tstatus\trepetitions\tvalue\tchange")
+	for i, p := range s.points {
+		change := ""
+		if i > 0 {
+			change = formatChange(values[i-1], p.value)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.3f\t%s\n", p.runID, p.label, p.status, p.countSteps, p.value, change)
+	}
+	w.Flush()
+	if len(values) > 1 {
+		fmt.Printf("first to last: %s\n", formatChange(values[0], values[len(values)-1]))
+	}
+	fmt.Println()
+}
+
+func formatChange(from float64, to float64) string {
+	switch {
+	case from != 0:
+		return fmt.Sprintf("%+.2f%%", (to-from)/math.Abs(from)*100)
+	case to != 0:
+		return fmt.Sprintf("%+.0f%%", math.Inf(int(math.Copysign(1, to))))
+	}
+	return "+0.00%"
+}
@@ -29,46 +29,152 @@ func (e ThroughputType) String() string {
 	}
 }
 
+// MarshalText encodes the type by its name, e.g. in JSON records
+func (e ThroughputType) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
 type CpuAndRam struct {
-	Pid          uint // Deprecated: kept for backward compatibility
-	Cpu          float64
-	Ram          uint
-	ProcessName  string // Name of the process being monitored
-	ProcessCount int    // Number of processes found with this name
+	Pid          uint    `json:"pid,omitempty"` // Deprecated: kept for backward compatibility
+	Cpu          float64 `json:"cpu_fraction"`
+	Ram          uint    `json:"ram_bytes"`
+	ProcessName  string  `json:"process_name,omitempty"`  // Name of the process being monitored
+	ProcessCount int     `json:"process_count,omitempty"` // Number of processes found with this name
 }
 
 // ProcessCpuAndRam represents CPU and RAM usage for multiple processes
 type ProcessCpuAndRam map[string]*CpuAndRam
 
 type BurstTest struct {
-	Duration         time.Duration
-	FailureRate      float64
-	CpuAndRam        *CpuAndRam       // Legacy single-process monitoring
-	ProcessCpuAndRam ProcessCpuAndRam // Multi-process monitoring
+	Duration               time.Duration    `json:"duration_ns"`
+	CountRequests          uint             `json:"count_requests"`
+	FailureRate            float64          `json:"failure_rate"`
+	CpuAndRam              *CpuAndRam       `json:"cpu_and_ram,omitempty"`         // Legacy single-process monitoring
+	ProcessCpuAndRam       ProcessCpuAndRam `json:"process_cpu_and_ram,omitempty"` // Multi-process monitoring
+	Latency                LatencyStats     `json:"latency"`                       // Latency of successful requests
+	CachedLatency          LatencyStats     `json:"cached_latency"`                // DNS only: latency of names that had been queried before
+	UncachedLatency        LatencyStats     `json:"uncached_latency"`              // DNS only: latency of names queried for the first time
+	Protocols              map[string]uint  `json:"protocols,omitempty"`           // HTTP only: count of responses by negotiated protocol, e.g. "HTTP/3.0"
+	Failures               FailureClasses   `json:"failures,omitempty"`            // HTTP only: count of failed requests by cause
+	CountFallbacks         uint             `json:"count_fallbacks"`               // HTTP/3 only: requests sent over TCP because QUIC failed
+	FallbackCost           time.Duration    `json:"fallback_cost_ns"`              // HTTP/3 only: time lost on failed QUIC attempts
+	CountNewConnections    uint             `json:"count_new_connections"`         // HTTP only: requests that opened a new connection
+	CountReusedConnections uint             `json:"count_reused_connections"`      // HTTP only: requests sent on a reused connection
+	ProxyPaths             map[string]uint  `json:"proxy_paths,omitempty"`         // HTTP and proxied DNS over TCP only: count of requests by proxy path, e.g. "DIRECT"
 }
 
 type RateTest struct {
-	FailureRate      float64
-	CpuAndRam        CpuAndRam        // Legacy single-process monitoring
-	ProcessCpuAndRam ProcessCpuAndRam // Multi-process monitoring
+	CountRequests          uint             `json:"count_requests"`
+	FailureRate            float64          `json:"failure_rate"`
+	CpuAndRam              CpuAndRam        `json:"cpu_and_ram"`                   // Legacy single-process monitoring
+	ProcessCpuAndRam       ProcessCpuAndRam `json:"process_cpu_and_ram,omitempty"` // Multi-process monitoring
+	Latency                LatencyStats     `json:"latency"`                       // Latency of successful requests
+	CachedLatency          LatencyStats     `json:"cached_latency"`                // DNS only: latency of names that had been queried before
+	UncachedLatency        LatencyStats     `json:"uncached_latency"`              // DNS only: latency of names queried for the first time
+	Protocols              map[string]uint  `json:"protocols,omitempty"`           // HTTP only: count of responses by negotiated protocol, e.g. "HTTP/3.0"
+	Failures               FailureClasses   `json:"failures,omitempty"`            // HTTP only: count of failed requests by cause
+	CountFallbacks         uint             `json:"count_fallbacks"`               // HTTP/3 only: requests sent over TCP because QUIC failed
+	FallbackCost           time.Duration    `json:"fallback_cost_ns"`              // HTTP/3 only: time lost on failed QUIC attempts
+	CountNewConnections    uint             `json:"count_new_connections"`         // HTTP only: requests that opened a new connection
+	CountReusedConnections uint             `json:"count_reused_connections"`      // HTTP only: requests sent on a reused connection
+	ProxyPaths             map[string]uint  `json:"proxy_paths,omitempty"`         // HTTP and proxied DNS over TCP only: count of requests by proxy path, e.g. "DIRECT"
 }
 
 type Fn func(int) int
 
 type ThroughputTest struct {
-	Type                  ThroughputType
-	CountBytesTransferred uint64
-	DurationNanoseconds   uint64
-	CpuAndRam             CpuAndRam        // Legacy single-process monitoring
-	ProcessCpuAndRam      ProcessCpuAndRam // Multi-process monitoring
+	Type                  ThroughputType   `json:"type"`
+	CountBytesTransferred uint64           `json:"count_bytes_transferred"`
+	DurationNanoseconds   uint64           `json:"duration_ns"`
+	CpuAndRam             CpuAndRam        `json:"cpu_and_ram"`                   // Legacy single-process monitoring
+	ProcessCpuAndRam      ProcessCpuAndRam `json:"process_cpu_and_ram,omitempty"` // Multi-process monitoring
+	Protocol              string           `json:"protocol,omitempty"`            // negotiated protocol, e.g. "HTTP/3.0"
+	CountFallbacks        uint             `json:"count_fallbacks"`               // HTTP/3 only: requests sent over TCP because QUIC failed
+	FallbackCost          time.Duration    `json:"fallback_cost_ns"`              // HTTP/3 only: time lost on failed QUIC attempts
+	ProxyPaths            map[string]uint  `json:"proxy_paths,omitempty"`         // count of requests by proxy path, e.g. "DIRECT"
+}
+
+// PingSample is a single round trip of a ping test
+type PingSample struct {
+	Sequence uint          `json:"sequence"`
+	RTT      time.Duration `json:"rtt_ns"` // zero when the sample was lost
+	Lost     bool          `json:"lost"`
+}
+
+// LatencyStats summarises the RTT distribution of a series of samples
+type LatencyStats struct {
+	CountSamples uint          `json:"count_samples"`
+	CountLost    uint          `json:"count_lost"`
+	Min          time.Duration `json:"min_ns"`
+	Max          time.Duration `json:"max_ns"`
+	Mean         time.Duration `json:"mean_ns"`
+	StdDev       time.Duration `json:"stddev_ns"`
+	P50          time.Duration `json:"p50_ns"`
+	P90          time.Duration `json:"p90_ns"`
+	P95          time.Duration `json:"p95_ns"`
+	P99          time.Duration `json:"p99_ns"`
+	Jitter       time.Duration `json:"jitter_ns"`    // RFC 3550 smoothed interarrival jitter
+	IPDV_Mean    time.Duration `json:"ipdv_mean_ns"` // mean absolute IP packet delay variation between consecutive samples
+	IPDV_Max     time.Duration `json:"ipdv_max_ns"`  // largest absolute IP packet delay variation between consecutive samples
+	Buckets      []uint        `json:"buckets"`      // count of samples at or below each of LatencyBucketBounds
+}
+
+// LatencyBucketBounds are the upper bounds of the buckets of a latency histogram
+var LatencyBucketBounds = []time.Duration{
+	time.Millisecond / 2, time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	200 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+// LossRate is the fraction of samples that were lost
+func (e LatencyStats) LossRate() float64 {
+	if e.CountSamples == 0 {
+		return 0
+	}
+	return float64(e.CountLost) / float64(e.CountSamples)
+}
+
+type PingTest struct {
+	Samples []PingSample `json:"samples"`
+	Stats   LatencyStats `json:"stats"`
+}
+
+// FailureClasses counts failures by cause, e.g. "timeout" or "rcode:SERVFAIL"
+type FailureClasses map[string]uint
+
+// DnsMatrixTest is the result of querying one record type at one response size
+type DnsMatrixTest struct {
+	QueryType         string         `json:"query_type"`
+	ResponseSize      uint           `json:"response_size_bytes"` // requested response size in bytes, 0 for the server's natural answer
+	CountQueries      uint           `json:"count_queries"`
+	CountFailures     uint           `json:"count_failures"`
+	CountTruncated    uint           `json:"count_truncated"`     // UDP responses with the TC bit set
+	CountTcpFallbacks uint           `json:"count_tcp_fallbacks"` // truncated responses that were successfully retried over TCP
+	MaxResponseBytes  uint           `json:"max_response_bytes"`  // largest response received
+	Latency           LatencyStats   `json:"latency"`
+	Failures          FailureClasses `json:"failures,omitempty"`
+}
+
+// WebSocketTest is the result of exchanging messages of one size with the companion server's WebSocket echo endpoint
+type WebSocketTest struct {
+	MessageSize           uint             `json:"message_size_bytes"`      // bytes per message
+	UpgradeLatency        time.Duration    `json:"upgrade_latency_ns"`      // time to connect and complete the WebSocket upgrade
+	Latency               LatencyStats     `json:"latency"`                 // RTT of sequential echoed messages
+	CountMessagesSent     uint             `json:"count_messages_sent"`     // messages sent during the message rate phase
+	CountMessagesReceived uint             `json:"count_messages_received"` // echoes received during the message rate phase
+	Duration              time.Duration    `json:"duration_ns"`             // length of the message rate phase
+	MessagesPerSecond     float64          `json:"messages_per_second"`     // echoes received per second during the message rate phase
+	Failures              FailureClasses   `json:"failures,omitempty"`
+	ProcessCpuAndRam      ProcessCpuAndRam `json:"process_cpu_and_ram,omitempty"` // Multi-process monitoring during the message rate phase
+	ProxyPath             string           `json:"proxy_path"`                    // e.g. "DIRECT" or "http://proxy:3128"
 }
 
 // Device Under Test Information
 type DUT_Info struct {
-	CPU_ModelName          string
-	CPU_CoreCount          uint
-	CPU_BaseClockFrequency uint // in Hz
-	RAM_Total              uint // amount of bytes
+	CPU_ModelName          string `json:"cpu_model_name"`
+	CPU_CoreCount          uint   `json:"cpu_core_count"`
+	CPU_BaseClockFrequency uint   `json:"cpu_base_clock_frequency_hz"` // in Hz
+	RAM_Total              uint   `json:"ram_total_bytes"`             // amount of bytes
 }
 
 func (e DUT_Info) String() string {
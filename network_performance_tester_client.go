@@ -1,15 +1,34 @@
 package main
 
 import (
+	"os"
+
 	"github.com/jrcamenzuli/network-performance-tester-client/client"
+	"github.com/jrcamenzuli/network-performance-tester-client/compare"
+	"github.com/jrcamenzuli/network-performance-tester-client/history"
+	"github.com/jrcamenzuli/network-performance-tester-client/report"
 	"github.com/jrcamenzuli/network-performance-tester-client/types"
 	"github.com/jrcamenzuli/network-performance-tester-client/util"
 )
 
 func main() {
+	// log to the console until a run reads its configuration
+	util.SetupLogging(types.LoggingConfig{}, nil)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compare":
+			os.Exit(compare.Run(os.Args[2:]))
+		case "history":
+			os.Exit(history.Run(os.Args[2:]))
+		case "report":
+			os.Exit(report.Run(os.Args[2:]))
+		}
+	}
 	args := util.Args()
 	var cfg types.Configuration
 	util.ReadFile(&cfg, args.ConfigFile)
 	util.ReadEnv(&cfg)
-	client.RunClient(&cfg)
+	if !client.RunClient(&cfg) {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,437 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+// runManifest is the part of a run's manifest.json that the report shows
+type runManifest struct {
+	RunID       string          `json:"run_id"`
+	Status      string          `json:"status"`
+	Started     time.Time       `json:"started"`
+	Ended       time.Time       `json:"ended"`
+	ToolVersion string          `json:"tool_version"`
+	GitCommit   string          `json:"git_commit"`
+	Host        map[string]any  `json:"host"`
+	Device      *model.DUT_Info `json:"device"`
+	Tests       []struct {
+		TestID          string    `json:"test_id"`
+		Status          string    `json:"status"`
+		Started         time.Time `json:"started"`
+		Ended           time.Time `json:"ended"`
+		CountSteps      int       `json:"count_steps"`
+		CountAssertions int       `json:"count_assertions"`
+		CountFailures   int       `json:"count_failures"`
+	} `json:"tests"`
+}
+
+// stepRecord is a line of a run's JSON Lines results, with the parts of the result that the charts need
+type stepRecord struct {
+	TestID  string             `json:"test_id"`
+	Step    string             `json:"step"`
+	Metrics map[string]float64 `json:"metrics"`
+	Result  struct {
+		Samples          []model.PingSample     `json:"samples"`             // ping tests
+		ProcessCpuAndRam model.ProcessCpuAndRam `json:"process_cpu_and_ram"` // tests that monitor processes
+	} `json:"result"`
+}
+
+// testSteps are the records of a test grouped by step, in the order the steps first ran
+type testSteps struct {
+	test    string
+	steps   []string
+	records map[string][]stepRecord
+}
+
+// section is a part of the report with its charts and tables
+type section struct {
+	Title  string
+	Charts []template.HTML
+	Tables []table
+}
+
+type table struct {
+	Caption string
+	Header  []string
+	Rows    [][]string
+}
+
+// page is the data of the report's template
+type page struct {
+	Manifest  runManifest
+	Generated string
+	Sections  []section
+	Config    string
+}
+
+// Run runs the report command with args and returns its exit code: 0 if the report was written and 2 on error
+func Run(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	out := flags.String("out", "", "HTML file to write, report.html in the run's directory if empty")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s report [flags] run-directory\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(flags.Output(), "Renders the results of a run as a self-contained HTML file with charts\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	directory := flags.Arg(0)
+	if *out == "" {
+		*out = filepath.Join(directory, "report.html")
+	}
+
+	data, err := loadRun(directory)
+	if err != nil {
+		slog.Error("Could not read the run", "error", err)
+		return 2
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		slog.Error("Could not write the report", "error", err)
+		return 2
+	}
+	defer f.Close()
+	if err := reportTemplate.Execute(f, data); err != nil {
+		slog.Error("Could not write the report", "error", err)
+		return 2
+	}
+	slog.Info("Report written", "file", *out)
+	return 0
+}
+
+// loadRun reads the manifest, results and configuration of the run in directory and lays out its report
+func loadRun(directory string) (*page, error) {
+	data := &page{Generated: time.Now().UTC().Format(time.RFC3339)}
+	contents, err := os.ReadFile(filepath.Join(directory, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a run directory: %v", directory, err)
+	}
+	if err := json.Unmarshal(contents, &data.Manifest); err != nil {
+		return nil, fmt.Errorf("could not read the manifest of %s: %v", directory, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(directory, "*-configInfo*.txt")); len(files) > 0 {
+		data.Config = loadConfig(files[0])
+	}
+
+	files, _ := filepath.Glob(filepath.Join(directory, "*-results*.jsonl"))
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no results found in %s", directory)
+	}
+	tests, err := loadRecords(files[0])
+	if err != nil {
+		return nil, err
+	}
+	data.Sections = append(data.Sections, throughputSection(tests)...)
+	data.Sections = append(data.Sections, burstSections(tests)...)
+	data.Sections = append(data.Sections, rateSections(tests)...)
+	data.Sections = append(data.Sections, latencySections(tests)...)
+	data.Sections = append(data.Sections, processSections(tests)...)
+	data.Sections = append(data.Sections, metricsSection(tests))
+	return data, nil
+}
+
+// loadConfig reads the configuration of a run and returns it with its secrets redacted, which runs from before the
+// configuration was redacted when saved may still contain. It returns none if the configuration cannot be read, rather
+// than showing it unredacted
+func loadConfig(filename string) string {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		slog.Warn("Could not read the configuration of the run, the report will not show it", "error", err)
+		return ""
+	}
+	var config types.Configuration
+	if err := json.Unmarshal(contents, &config); err != nil {
+		slog.Warn("Could not read the configuration of the run, the report will not show it", "file", filename, "error", err)
+		return ""
+	}
+	return util.PrettifyStruct(util.RedactConfig(config))
+}
+
+// loadRecords reads the records of a JSON Lines results file grouped by test, in the order the tests ran
+func loadRecords(filename string) ([]*testSteps, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tests []*testSteps
+	byTest := map[string]*testSteps{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024) // ping records hold every sample
+	for scanner.Scan() {
+		var record stepRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", filename, err)
+		}
+		test := byTest[record.TestID]
+		if test == nil {
+			test = &testSteps{test: record.TestID, records: map[string][]stepRecord{}}
+			byTest[record.TestID] = test
+			tests = append(tests, test)
+		}
+		if test.records[record.Step] == nil {
+			test.steps = append(test.steps, record.Step)
+		}
+		test.records[record.Step] = append(test.records[record.Step], record)
+	}
+	return tests, scanner.Err()
+}
+
+// mean returns the mean of a metric over the repetitions of a step, NaN if it has none
+func (e *testSteps) mean(step string, metric string) float64 {
+	sum, count := 0.0, 0
+	for _, record := range e.records[step] {
+		if value, ok := record.Metrics[metric]; ok {
+			sum += value
+			count++
+		}
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
+
+// metricSeries returns the mean of each metric at each step, scaled by scale, e.g. to show a fraction as a percentage
+func (e *testSteps) metricSeries(scale float64, metrics ...string) []chartSeries {
+	var series []chartSeries
+	for _, metric := range metrics {
+		s := chartSeries{name: metric}
+		for _, step := range e.steps {
+			s.values = append(s.values, e.mean(step, metric)*scale)
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// throughputSection compares the throughput of each throughput test by throughput type
+func throughputSection(tests []*testSteps) []section {
+	var types []string
+	var series []chartSeries
+	for _, test := range tests {
+		if !strings.HasSuffix(test.test, "_throughput") {
+			continue
+		}
+		for _, step := range test.steps {
+			if !slices.Contains(types, step) {
+				types = append(types, step)
+			}
+		}
+		series = append(series, chartSeries{name: test.test})
+	}
+	if len(series) == 0 {
+		return nil
+	}
+	i := 0
+	for _, test := range tests {
+		if !strings.HasSuffix(test.test, "_throughput") {
+			continue
+		}
+		for _, throughputType := range types {
+			series[i].values = append(series[i].values, test.mean(throughputType, "throughput_mbps"))
+		}
+		i++
+	}
+	return []section{{Title: "Throughput", Charts: []template.HTML{barChart("Throughput by type", "throughput type", "Mbit/s", types, series)}}}
+}
+
+// burstSections show the time to complete each burst and its latency by burst size
+func burstSections(tests []*testSteps) []section {
+	var charts []template.HTML
+	for _, test := range tests {
+		if strings.HasSuffix(test.test, "_burst") {
+			charts = append(charts, lineChart(test.test+": burst size vs time", "burst size (requests)", "ms", test.steps, test.metricSeries(1, "time_to_complete_ms", "p99_latency_ms", "mean_latency_ms")))
+		}
+	}
+	if len(charts) == 0 {
+		return nil
+	}
+	return []section{{Title: "Bursts", Charts: charts}}
+}
+
+// rateSections show the failure rate and latency of each rate test by request rate
+func rateSections(tests []*testSteps) []section {
+	var charts []template.HTML
+	for _, test := range tests {
+		if strings.HasSuffix(test.test, "_rate") {
+			charts = append(charts,
+				lineChart(test.test+": rate vs failures", "requests per second", "failed requests (%)", test.steps, test.metricSeries(100, "failure_rate")),
+				lineChart(test.test+": rate vs latency", "requests per second", "ms", test.steps, test.metricSeries(1, "mean_latency_ms", "p95_latency_ms", "p99_latency_ms")))
+		}
+	}
+	if len(charts) == 0 {
+		return nil
+	}
+	return []section{{Title: "Rates", Charts: charts}}
+}
+
+// latencySections show the distribution of the round trip times of the ping tests and, for the jitter test, their
+// sequence
+func latencySections(tests []*testSteps) []section {
+	var charts []template.HTML
+	for _, test := range tests {
+		for _, step := range test.steps {
+			for repetition, record := range test.records[step] {
+				var rtts []float64
+				var sequence []string
+				var sequenceRTTs []float64
+				for _, sample := range record.Result.Samples {
+					rtt := math.NaN()
+					if !sample.Lost {
+						rtt = float64(sample.RTT) / float64(time.Millisecond)
+						rtts = append(rtts, rtt)
+					}
+					sequence = append(sequence, fmt.Sprint(sample.Sequence))
+					sequenceRTTs = append(sequenceRTTs, rtt)
+				}
+				if len(rtts) == 0 {
+					continue
+				}
+				title := fmt.Sprintf("%s %s", test.test, step)
+				if len(test.records[step]) > 1 {
+					title += fmt.Sprintf(" #%d", repetition+1)
+				}
+				buckets, counts := histogram(rtts, 20)
+				charts = append(charts, barChart(title+": RTT distribution", "RTT (ms)", "samples", buckets, []chartSeries{{values: counts}}))
+				if test.test == "jitter" {
+					charts = append(charts, lineChart(title+": RTT by sequence", "sequence number", "RTT (ms)", sequence, []chartSeries{{values: sequenceRTTs}}))
+				}
+			}
+		}
+	}
+	if len(charts) == 0 {
+		return nil
+	}
+	return []section{{Title: "Ping and jitter", Charts: charts}}
+}
+
+// histogram counts values in count buckets of equal width from their minimum to their maximum, and labels each bucket
+// with its lower bound
+func histogram(values []float64, count int) ([]string, []float64) {
+	min, max := values[0], values[0]
+	for _, value := range values {
+		min, max = math.Min(min, value), math.Max(max, value)
+	}
+	width := (max - min) / float64(count)
+	if width == 0 {
+		return []string{formatValue(min)}, []float64{float64(len(values))}
+	}
+	labels := make([]string, count)
+	counts := make([]float64, count)
+	for i := range labels {
+		labels[i] = formatValue(min + float64(i)*width)
+	}
+	for _, value := range values {
+		i := int((value - min) / width)
+		if i == count {
+			i--
+		}
+		counts[i]++
+	}
+	return labels, counts
+}
+
+// processSections overlay the CPU and RAM usage of the monitored processes on the steps of each test that monitored
+// them
+func processSections(tests []*testSteps) []section {
+	var charts []template.HTML
+	for _, test := range tests {
+		var processes []string
+		for _, step := range test.steps {
+			for _, record := range test.records[step] {
+				for name := range record.Result.ProcessCpuAndRam {
+					if !slices.Contains(processes, name) {
+						processes = append(processes, name)
+					}
+				}
+			}
+		}
+		if len(processes) == 0 {
+			continue
+		}
+		sort.Strings(processes)
+		var cpu, ram []chartSeries
+		for _, name := range processes {
+			cpuSeries, ramSeries := chartSeries{name: name}, chartSeries{name: name}
+			for _, step := range test.steps {
+				cpuSum, ramSum, count := 0.0, 0.0, 0
+				for _, record := range test.records[step] {
+					if usage := record.Result.ProcessCpuAndRam[name]; usage != nil {
+						cpuSum += usage.Cpu * 100
+						ramSum += float64(usage.Ram) / 1e6
+						count++
+					}
+				}
+				if count == 0 {
+					cpuSeries.values = append(cpuSeries.values, math.NaN())
+					ramSeries.values = append(ramSeries.values, math.NaN())
+					continue
+				}
+				cpuSeries.values = append(cpuSeries.values, cpuSum/float64(count))
+				ramSeries.values = append(ramSeries.values, ramSum/float64(count))
+			}
+			cpu, ram = append(cpu, cpuSeries), append(ram, ramSeries)
+		}
+		charts = append(charts,
+			lineChart(test.test+": process CPU", "step", "CPU (%)", test.steps, cpu),
+			lineChart(test.test+": process RAM", "step", "RAM (MB)", test.steps, ram))
+	}
+	if len(charts) == 0 {
+		return nil
+	}
+	return []section{{Title: "Processes", Charts: charts}}
+}
+
+// metricsSection tabulates the mean of every metric of every step of every test
+func metricsSection(tests []*testSteps) section {
+	result := section{Title: "All metrics"}
+	for _, test := range tests {
+		var metrics []string
+		for _, step := range test.steps {
+			for _, record := range test.records[step] {
+				for metric := range record.Metrics {
+					if !slices.Contains(metrics, metric) {
+						metrics = append(metrics, metric)
+					}
+				}
+			}
+		}
+		sort.Strings(metrics)
+		t := table{Caption: test.test, Header: append([]string{"step", "repetitions"}, metrics...)}
+		for _, step := range test.steps {
+			row := []string{step, fmt.Sprint(len(test.records[step]))}
+			for _, metric := range metrics {
+				if value := test.mean(step, metric); !math.IsNaN(value) {
+					row = append(row, fmt.Sprintf("%.3f", value))
+				} else {
+					row = append(row, "")
+				}
+			}
+			t.Rows = append(t.Rows, row)
+		}
+		result.Tables = append(result.Tables, t)
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// chartSeries is a named series of values, one per category of a chart, NaN where it has none
+type chartSeries struct {
+	name   string
+	values []float64
+}
+
+const (
+	chartWidth   = 640
+	chartHeight  = 300
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 40
+	marginBottom = 56
+)
+
+var palette = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+// lineChart draws the series as lines over evenly spaced categories, e.g. the steps of a test
+func lineChart(title string, xLabel string, yLabel string, categories []string, series []chartSeries) template.HTML {
+	var svg strings.Builder
+	plot := startChart(&svg, title, xLabel, yLabel, categories, series)
+	for i, s := range series {
+		color := palette[i%len(palette)]
+		var segment []string
+		var points strings.Builder // drawn over the lines
+		flush := func() {
+			if len(segment) > 1 {
+				fmt.Fprintf(&svg, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(segment, " "))
+			}
+			segment = nil
+		}
+		for j, value := range s.values {
+			if math.IsNaN(value) {
+				flush()
+				continue
+			}
+			x, y := plot.x(j), plot.y(value)
+			segment = append(segment, fmt.Sprintf("%.1f,%.1f", x, y))
+			fmt.Fprintf(&points, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %s</title></circle>`, x, y, color, html.EscapeString(strings.TrimSpace(s.name+" "+categories[j])), formatValue(value))
+		}
+		flush()
+		svg.WriteString(points.String())
+	}
+	return endChart(&svg, series)
+}
+
+// barChart draws the series as groups of bars, a group per category
+func barChart(title string, xLabel string, yLabel string, categories []string, series []chartSeries) template.HTML {
+	var svg strings.Builder
+	plot := startChart(&svg, title, xLabel, yLabel, categories, series)
+	groupWidth := plot.step * 0.8
+	barWidth := groupWidth / float64(len(series))
+	for i, s := range series {
+		color := palette[i%len(palette)]
+		for j, value := range s.values {
+			if math.IsNaN(value) {
+				continue
+			}
+			x := plot.x(j) - groupWidth/2 + float64(i)*barWidth
+			y := plot.y(value)
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`, x, y, math.Max(barWidth-1, 1), plot.y(0)-y, color, html.EscapeString(strings.TrimSpace(s.name+" "+categories[j])), formatValue(value))
+		}
+	}
+	return endChart(&svg, series)
+}
+
+// plotArea maps categories and values to the coordinates of a chart
+type plotArea struct {
+	step float64 // horizontal distance between categories
+	max  float64 // value at the top of the plot
+}
+
+func (e plotArea) x(i int) float64 {
+	return marginLeft + e.step*(float64(i)+0.5)
+}
+
+func (e plotArea) y(value float64) float64 {
+	height := float64(chartHeight - marginTop - marginBottom)
+	return float64(chartHeight-marginBottom) - value/e.max*height
+}
+
+// startChart draws the title, axes, grid and category labels of a chart scaled to fit every value of series
+func startChart(svg *strings.Builder, title string, xLabel string, yLabel string, categories []string, series []chartSeries) plotArea {
+	max := 0.0
+	for _, s := range series {
+		for _, value := range s.values {
+			if !math.IsNaN(value) {
+				max = math.Max(max, value)
+			}
+		}
+	}
+	tick := niceTick(max / 4)
+	plot := plotArea{step: float64(chartWidth-marginLeft-marginRight) / math.Max(float64(len(categories)), 1), max: tick * math.Max(math.Ceil(max/tick), 1)}
+
+	fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" font-family="sans-serif" font-size="11">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(svg, `<text x="%d" y="18" font-size="14" font-weight="bold">%s</text>`, marginLeft, html.EscapeString(title))
+	for value := 0.0; value <= plot.max*1.0001; value += tick {
+		y := plot.y(value)
+		fmt.Fprintf(svg, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`, marginLeft, y, chartWidth-marginRight, y)
+		fmt.Fprintf(svg, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, marginLeft-6, y+4, formatValue(value))
+	}
+	fmt.Fprintf(svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, marginLeft, chartHeight-marginBottom, chartWidth-marginRight, chartHeight-marginBottom)
+	// label at most about 16 categories so that they do not overlap
+	every := (len(categories) + 15) / 16
+	for i, category := range categories {
+		if i%every == 0 {
+			fmt.Fprintf(svg, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, plot.x(i), chartHeight-marginBottom+16, html.EscapeString(category))
+		}
+	}
+	fmt.Fprintf(svg, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, marginLeft+(chartWidth-marginLeft-marginRight)/2, chartHeight-14, html.EscapeString(xLabel))
+	fmt.Fprintf(svg, `<text transform="translate(14 %d) rotate(-90)" text-anchor="middle">%s</text>`, marginTop+(chartHeight-marginTop-marginBottom)/2, html.EscapeString(yLabel))
+	return plot
+}
+
+// endChart draws the legend of the named series of a chart and returns the chart
+func endChart(svg *strings.Builder, series []chartSeries) template.HTML {
+	x := chartWidth - marginRight
+	for i := len(series) - 1; i >= 0; i-- {
+		if series[i].name != "" {
+			x -= 7*len(series[i].name) + 24
+			fmt.Fprintf(svg, `<rect x="%d" y="24" width="10" height="10" fill="%s"/><text x="%d" y="33">%s</text>`, x, palette[i%len(palette)], x+14, html.EscapeString(series[i].name))
+		}
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// niceTick returns the smallest of 1, 2 or 5 times a power of 10 that is at least step
+func niceTick(step float64) float64 {
+	if step <= 0 || math.IsInf(step, 0) || math.IsNaN(step) {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		if factor*magnitude >= step {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatValue(value float64) string {
+	if value != 0 && math.Abs(value) < 0.01 {
+		return fmt.Sprintf("%.2g", value)
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
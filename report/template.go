@@ -0,0 +1,60 @@
+package report
+
+import "html/template"
+
+// reportTemplate renders a page as a single HTML file without external resources, so that it can be mailed or archived
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Network performance run {{.Manifest.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 1320px; color: #222; }
+h1 { margin-bottom: 0.2em; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 0.2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 1em 0; font-size: 0.9em; }
+caption { text-align: left; font-weight: bold; padding: 0.3em 0; }
+th, td { border: 1px solid #ddd; padding: 0.25em 0.6em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+.passed { color: #2e7d32; }
+.failed, .invalid { color: #c62828; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>Run {{.Manifest.RunID}} <span class="{{.Manifest.Status}}">{{.Manifest.Status}}</span></h1>
+<p>From {{.Manifest.Started.Format "2006-01-02 15:04:05 MST"}} to {{.Manifest.Ended.Format "2006-01-02 15:04:05 MST"}}, version {{.Manifest.ToolVersion}}{{with .Manifest.GitCommit}} ({{.}}){{end}}. Generated {{.Generated}}.</p>
+
+<h2>Device under test</h2>
+<table>
+{{with .Manifest.Host}}<tr><th>host</th><td>{{.hostname}}</td></tr>
+<tr><th>OS</th><td>{{.os}}/{{.arch}} {{.platform}} {{.platform_version}}</td></tr>
+<tr><th>kernel</th><td>{{.kernel_version}}</td></tr>{{end}}
+{{with .Manifest.Device}}<tr><th>CPU</th><td>{{.CPU_ModelName}}</td></tr>
+<tr><th>cores</th><td>{{.CPU_CoreCount}}</td></tr>
+<tr><th>base clock (Hz)</th><td>{{.CPU_BaseClockFrequency}}</td></tr>
+<tr><th>RAM (bytes)</th><td>{{.RAM_Total}}</td></tr>{{end}}
+</table>
+
+<h2>Tests</h2>
+<table>
+<tr><th>test</th><th>status</th><th>steps</th><th>assertions</th><th>failures</th><th>started</th><th>ended</th></tr>
+{{range .Manifest.Tests}}<tr><td>{{.TestID}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.CountSteps}}</td><td>{{.CountAssertions}}</td><td>{{.CountFailures}}</td><td>{{.Started.Format "15:04:05"}}</td><td>{{.Ended.Format "15:04:05"}}</td></tr>
+{{end}}</table>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+<div class="charts">{{range .Charts}}
+{{.}}{{end}}
+</div>
+{{range .Tables}}<table>
+<caption>{{.Caption}}</caption>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{end}}
+{{with .Config}}<h2>Configuration</h2>
+<pre>{{.}}</pre>{{end}}
+</body>
+</html>
+`))
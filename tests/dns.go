@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"github.com/miekg/dns"
+)
+
+// dnsResponse is the outcome of a single DNS query
+type dnsResponse struct {
+	msg       *dns.Msg
+	rtt       time.Duration // total time taken, including any TCP fallback
+	truncated bool          // the UDP response had the TC bit set
+	fellBack  bool          // the query was retried over TCP
+	proxyPath string        // the proxy path of a proxied query, e.g. "socks5://proxy:1080"
+}
+
+// dnsExchange sends msg to address and, like a stub resolver, retries over TCP when a UDP response is truncated
+func dnsExchange(client *dns.Client, msg *dns.Msg, address string) (dnsResponse, error) {
+	tStart := time.Now()
+	resp, _, err := client.Exchange(msg, address)
+	if err != nil {
+		return dnsResponse{rtt: time.Since(tStart)}, err
+	}
+	result := dnsResponse{msg: resp, truncated: resp.Truncated}
+	if resp.Truncated && client.Net == "udp" {
+		tcpClient := dns.Client{Net: "tcp", Timeout: client.Timeout}
+		result.fellBack = true
+		resp, _, err = tcpClient.Exchange(msg, address)
+		if err != nil {
+			result.rtt = time.Since(tStart)
+			return result, err
+		}
+		result.msg = resp
+	}
+	result.rtt = time.Since(tStart)
+	if resp.Rcode != dns.RcodeSuccess {
+		return result, fmt.Errorf("DNS query error: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return result, nil
+}
+
+// dnsLatencies collects the latency of successful queries, split by whether the answer should have been cached,
+// and the proxy paths of proxied queries
+type dnsLatencies struct {
+	mutex      sync.Mutex
+	all        []time.Duration
+	cached     []time.Duration
+	uncached   []time.Duration
+	proxyPaths map[string]uint
+}
+
+func (e *dnsLatencies) add(rtt time.Duration, cached bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.all = append(e.all, rtt)
+	if cached {
+		e.cached = append(e.cached, rtt)
+	} else {
+		e.uncached = append(e.uncached, rtt)
+	}
+}
+
+func (e *dnsLatencies) addProxyPath(path string) {
+	if path == "" {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.proxyPaths == nil {
+		e.proxyPaths = map[string]uint{}
+	}
+	e.proxyPaths[path]++
+}
+
+func (e *dnsLatencies) stats() (all model.LatencyStats, cached model.LatencyStats, uncached model.LatencyStats) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return util.ComputeLatencyStats(e.all), util.ComputeLatencyStats(e.cached), util.ComputeLatencyStats(e.uncached)
+}
+
+// classifyDnsFailure returns the failure class of a DNS query
+func classifyDnsFailure(resp dnsResponse, err error) string {
+	if resp.msg != nil && resp.msg.Rcode != dns.RcodeSuccess {
+		return "rcode:" + dns.RcodeToString[resp.msg.Rcode]
+	}
+	return util.ClassifyError(err)
+}
+
+// ParseDnsQueryTypes converts record type names such as "AAAA" or "HTTPS" to their DNS type codes
+func ParseDnsQueryTypes(names []string) ([]uint16, error) {
+	var queryTypes []uint16
+	for _, name := range names {
+		queryType, ok := dns.StringToType[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown DNS record type %q", name)
+		}
+		queryTypes = append(queryTypes, queryType)
+	}
+	return queryTypes, nil
+}
+
+// DnsSizedQueryName returns the name to query for a response of about responseSize bytes.
+// The companion server pads answers to names of the form size-<bytes>.<name>, 0 queries the name itself.
+func DnsSizedQueryName(name string, responseSize uint) string {
+	if responseSize == 0 {
+		return dns.Fqdn(name)
+	}
+	return dns.Fqdn(fmt.Sprintf("size-%d.%s", responseSize, name))
+}
+
+// DnsMatrixTest sends countQueries sequential queries of one record type and response size and reports latency and failures
+func DnsMatrixTest(name string, queryType uint16, responseSize uint, countQueries uint, ednsBufferSize uint16, transport *DnsTransport) model.DnsMatrixTest {
+	queryName := DnsSizedQueryName(name, responseSize)
+	slog.Info(fmt.Sprintf("Sending %d DNS over %s %s queries", countQueries, strings.ToUpper(transport.Name), dns.TypeToString[queryType]), "name", queryName, "address", transport.Address)
+
+	result := model.DnsMatrixTest{
+		QueryType:    dns.TypeToString[queryType],
+		ResponseSize: responseSize,
+		CountQueries: countQueries,
+		Failures:     model.FailureClasses{},
+	}
+	var rtts []time.Duration
+	for i := uint(0); i < countQueries; i++ {
+		msg := dns.Msg{}
+		msg.SetQuestion(queryName, queryType)
+		if ednsBufferSize > 0 {
+			msg.SetEdns0(ednsBufferSize, false)
+		}
+		resp, err := transport.Exchange(&msg)
+		if resp.truncated {
+			result.CountTruncated++
+		}
+		if err != nil {
+			result.CountFailures++
+			result.Failures[classifyDnsFailure(resp, err)]++
+			reportRequest(0, classifyDnsFailure(resp, err))
+			continue
+		}
+		if resp.fellBack {
+			result.CountTcpFallbacks++
+		}
+		if size := uint(resp.msg.Len()); size > result.MaxResponseBytes {
+			result.MaxResponseBytes = size
+		}
+		rtts = append(rtts, resp.rtt)
+		reportRequest(resp.rtt, "")
+	}
+	result.Latency = util.ComputeLatencyStats(rtts)
+	result.Latency.CountSamples = countQueries
+	result.Latency.CountLost = result.CountFailures
+	return result
+}
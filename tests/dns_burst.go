@@ -2,6 +2,7 @@ package tests
 
 import (
 	"fmt"
+	"log/slog"
 	"math"
 	"net"
 	"os"
@@ -27,12 +28,13 @@ func test() {
 }
 
 // todo
-func DnsBurstTest(url string, burstSize int, pid uint, serverHost string, serverPort uint, transportProtocol string, processNames []string) model.BurstTest {
+func DnsBurstTest(names *DnsNameSource, queryTypes []uint16, burstSize int, pid uint, transport *DnsTransport, processNames []string) model.BurstTest {
 	countRequests := int32(0)
 	countResponses := int32(0)
+	var latencies dnsLatencies
 	var wg sync.WaitGroup
 
-	fmt.Printf("Sending a burst of %d DNS over %s queries to %s:%d\n", burstSize, strings.ToUpper(transportProtocol), serverHost, serverPort)
+	slog.Info(fmt.Sprintf("Sending a burst of %d DNS over %s queries", burstSize, strings.ToUpper(transport.Name)), "address", transport.Address)
 
 	// Start monitoring processes if provided
 	var processMonitoringDone sync.WaitGroup
@@ -49,31 +51,31 @@ func DnsBurstTest(url string, burstSize int, pid uint, serverHost string, server
 
 	tStart := time.Now()
 	for i := 0; i < burstSize; i++ {
+		queryType := queryTypes[i%len(queryTypes)]
+		name, cached := names.Next(queryType)
 		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
+		go func(wg *sync.WaitGroup, name string, cached bool, queryType uint16) {
 			defer wg.Done()
 
-			// Create a new client and message for each goroutine to avoid race conditions
-			c := dns.Client{Net: transportProtocol}
+			// Create a new message for each goroutine to avoid race conditions
 			msg := dns.Msg{}
-			msg.SetQuestion(dns.Fqdn(url), dns.TypeA)
+			msg.SetQuestion(name, queryType)
 
-			resp, _, err := c.Exchange(&msg, fmt.Sprintf("%s:%d", serverHost, serverPort))
+			resp, err := transport.Exchange(&msg)
+			latencies.addProxyPath(resp.proxyPath)
 			atomic.AddInt32(&countRequests, 1)
 
 			if err != nil {
-				fmt.Println("Error:", err)
-				return
-			}
-
-			if resp.Rcode != dns.RcodeSuccess {
-				fmt.Printf("DNS query error: %s\n", dns.RcodeToString[resp.Rcode])
+				slog.Error("DNS query failed", "transport", transport.Name, "name", name, "error", err)
+				reportRequest(0, classifyDnsFailure(resp, err))
 				return
 			}
 
 			// Don't print IP address for successful queries - only count them
 			atomic.AddInt32(&countResponses, 1)
-		}(&wg)
+			latencies.add(resp.rtt, cached)
+			reportRequest(resp.rtt, "")
+		}(&wg, name, cached, queryType)
 	}
 	cpuAndRam := util.GetCPUandRAM(pid)
 	wg.Wait()
@@ -88,13 +90,19 @@ func DnsBurstTest(url string, burstSize int, pid uint, serverHost string, server
 	// Print summary
 	successfulQueries := atomic.LoadInt32(&countResponses)
 	totalQueries := atomic.LoadInt32(&countRequests)
-	fmt.Printf("DNS Burst Test Summary: %d/%d queries successful in %dms\n", successfulQueries, totalQueries, duration.Milliseconds())
+	slog.Info("DNS burst done", "successful", successfulQueries, "queries", totalQueries, "duration", duration.Round(time.Millisecond))
 
 	failureRate := math.Max(0, 1.0-float64(countResponses)/float64(countRequests))
+	latency, cachedLatency, uncachedLatency := latencies.stats()
 	return model.BurstTest{
 		Duration:         duration,
+		CountRequests:    uint(totalQueries),
 		FailureRate:      failureRate,
 		CpuAndRam:        cpuAndRam,
 		ProcessCpuAndRam: processUsage,
+		Latency:          latency,
+		CachedLatency:    cachedLatency,
+		UncachedLatency:  uncachedLatency,
+		ProxyPaths:       latencies.proxyPaths,
 	}
 }
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DnsNameSource generates the names queried by the DNS burst and rate tests.
+// In "fixed" mode the same name is always queried, so every query after the first is answered from any cache on the path.
+// In "random" mode every query is for a new random subdomain, which no cache can have answered before.
+// In "list" mode names are read in order from a domain list file, wrapping around at the end.
+// In the "random" and "list" modes revisitRatio of the queries repeat a previously queried name to measure cached latency.
+type DnsNameSource struct {
+	mode         string
+	name         string
+	list         []string
+	next         int
+	revisitRatio float64
+	queried      []string
+	seenNames    map[string]bool
+	seen         map[dnsQuestion]bool
+	rand         *rand.Rand
+	mutex        sync.Mutex
+}
+
+// NewDnsNameSource creates a name source for the given mode, reading listFile in "list" mode
+func NewDnsNameSource(mode string, name string, listFile string, revisitRatio float64) (*DnsNameSource, error) {
+	source := &DnsNameSource{
+		mode:         mode,
+		name:         dns.Fqdn(name),
+		revisitRatio: revisitRatio,
+		seenNames:    map[string]bool{},
+		seen:         map[dnsQuestion]bool{},
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	switch mode {
+	case "", "fixed":
+		source.mode = "fixed"
+	case "random":
+	case "list":
+		f, err := os.Open(listFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			source.list = append(source.list, dns.Fqdn(line))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if len(source.list) == 0 {
+			return nil, fmt.Errorf("domain list file %s has no names", listFile)
+		}
+	default:
+		return nil, fmt.Errorf("unknown DNS name mode %q", mode)
+	}
+	return source, nil
+}
+
+// dnsQuestion is a name and record type, which a cache answers separately from the other types of the name
+type dnsQuestion struct {
+	name      string
+	queryType uint16
+}
+
+// Next returns the next name to query for queryType and whether it has been queried for queryType before, in which case
+// its answer should be cached
+func (e *DnsNameSource) Next(queryType uint16) (string, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var name string
+	switch {
+	case e.mode == "fixed":
+		name = e.name
+	case len(e.queried) > 0 && e.rand.Float64() < e.revisitRatio:
+		name = e.queried[e.rand.Intn(len(e.queried))]
+	case e.mode == "random":
+		name = fmt.Sprintf("%016x.%s", e.rand.Uint64(), e.name)
+	default:
+		name = e.list[e.next%len(e.list)]
+		e.next++
+	}
+
+	if !e.seenNames[name] {
+		e.seenNames[name] = true
+		e.queried = append(e.queried, name)
+	}
+	cached := e.seen[dnsQuestion{name, queryType}]
+	e.seen[dnsQuestion{name, queryType}] = true
+	return name, cached
+}
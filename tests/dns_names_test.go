@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDnsNameSourceCachesPerQueryType(t *testing.T) {
+	names, err := NewDnsNameSource("fixed", "test.service", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		queryType uint16
+		cached    bool
+	}{{dns.TypeA, false}, {dns.TypeAAAA, false}, {dns.TypeA, true}, {dns.TypeAAAA, true}, {dns.TypeTXT, false}} {
+		name, cached := names.Next(c.queryType)
+		if name != "test.service." {
+			t.Errorf("query %d: name %q, want test.service.", i, name)
+		}
+		if cached != c.cached {
+			t.Errorf("query %d for %s: cached %v, want %v", i, dns.TypeToString[c.queryType], cached, c.cached)
+		}
+	}
+}
+
+func TestDnsNameSourceListWithSeveralQueryTypes(t *testing.T) {
+	listFile := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(listFile, []byte("# names\na.example\n\nb.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := NewDnsNameSource("list", "test.service", listFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// query types cycle separately from the names, as in the burst and rate tests
+	queryTypes := []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeMX}
+	want := []struct {
+		name   string
+		cached bool
+	}{
+		{"a.example.", false}, // A
+		{"b.example.", false}, // AAAA
+		{"a.example.", false}, // MX
+		{"b.example.", false}, // A
+		{"a.example.", false}, // AAAA
+		{"b.example.", false}, // MX
+		{"a.example.", true},  // A again
+		{"b.example.", true},  // AAAA again
+	}
+	for i, w := range want {
+		name, cached := names.Next(queryTypes[i%len(queryTypes)])
+		if name != w.name || cached != w.cached {
+			t.Errorf("query %d: %s cached %v, want %s cached %v", i, name, cached, w.name, w.cached)
+		}
+	}
+}
+
+func TestDnsNameSourceRandomIsNeverCached(t *testing.T) {
+	names, err := NewDnsNameSource("random", "test.service", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		name, cached := names.Next(dns.TypeA)
+		if cached || seen[name] {
+			t.Fatalf("query %d: %s cached %v, seen before %v", i, name, cached, seen[name])
+		}
+		seen[name] = true
+	}
+}
@@ -0,0 +1,264 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+const dnsTimeout = 2 * time.Second
+
+// DnsTransport sends DNS queries to the companion server over one of the supported transports:
+// "udp", "tcp", "tcp-tls" (DNS over TLS), "https-get" and "https-post" (DNS over HTTPS, RFC 8484) and "quic" (DNS over QUIC, RFC 9250).
+// Queries over "tcp" can be sent through a proxy.
+type DnsTransport struct {
+	Name       string
+	Address    string
+	client     *dns.Client
+	httpClient *http.Client
+	dohURL     string
+	tlsConfig  *tls.Config
+	quicConn   *quic.Conn
+	quicDialer *util.QUICDialer
+	proxy      *util.ProxySelector
+	mutex      sync.Mutex
+}
+
+// NewDnsTransport creates a transport to serverHost:serverPort, using dohPath as the URL path for DNS over HTTPS.
+// Every transport connects from localAddress if it is not empty.
+// If proxy is not nil, DNS over TCP is sent through the proxy it selects.
+func NewDnsTransport(transportProtocol string, serverHost string, serverPort uint, dohPath string, localAddress string, proxy *util.ProxySelector) (*DnsTransport, error) {
+	transport := &DnsTransport{
+		Name:    transportProtocol,
+		Address: net.JoinHostPort(serverHost, strconv.Itoa(int(serverPort))),
+	}
+	switch transportProtocol {
+	case "udp":
+		transport.client = &dns.Client{Net: transportProtocol, Timeout: dnsTimeout, Dialer: util.LocalDialer("udp", localAddress, dnsTimeout)}
+	case "tcp":
+		transport.client = &dns.Client{Net: transportProtocol, Timeout: dnsTimeout, Dialer: util.LocalDialer("tcp", localAddress, dnsTimeout)}
+		transport.proxy = proxy
+	case "tcp-tls":
+		tlsConfig := util.CreateTLSConfig()
+		tlsConfig.ServerName = serverHost
+		transport.client = &dns.Client{Net: transportProtocol, Timeout: dnsTimeout, TLSConfig: tlsConfig, Dialer: util.LocalDialer("tcp", localAddress, dnsTimeout)}
+	case "https-get", "https-post":
+		if dohPath == "" {
+			dohPath = "/dns-query"
+		}
+		transport.httpClient = util.CreateHTTPSClient()
+		transport.httpClient.Timeout = dnsTimeout
+		if localAddress != "" {
+			httpTransport, ok := transport.httpClient.Transport.(*http.Transport)
+			if !ok {
+				httpTransport = &http.Transport{}
+				transport.httpClient.Transport = httpTransport
+			}
+			httpTransport.DialContext = util.LocalDialer("tcp", localAddress, dnsTimeout).DialContext
+		}
+		transport.dohURL = fmt.Sprintf("https://%s%s", transport.Address, dohPath)
+	case "quic":
+		transport.tlsConfig = util.CreateTLSConfig()
+		transport.tlsConfig.ServerName = serverHost
+		transport.tlsConfig.NextProtos = []string{"doq"}
+		transport.quicDialer = util.NewQUICDialer(localAddress)
+	default:
+		return nil, fmt.Errorf("unknown DNS transport %q", transportProtocol)
+	}
+	return transport, nil
+}
+
+// Close closes the DNS over QUIC connection and its socket and the idle DNS over HTTPS connections of the transport
+func (e *DnsTransport) Close() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.quicConn != nil {
+		e.quicConn.CloseWithError(0, "")
+		e.quicConn = nil
+	}
+	if e.quicDialer != nil {
+		e.quicDialer.Close()
+	}
+	if e.httpClient != nil {
+		e.httpClient.CloseIdleConnections()
+	}
+}
+
+// Exchange sends msg and waits for the response
+func (e *DnsTransport) Exchange(msg *dns.Msg) (dnsResponse, error) {
+	if e.proxy != nil {
+		return e.exchangeProxied(msg)
+	}
+	if e.client != nil {
+		return dnsExchange(e.client, msg, e.Address)
+	}
+	tStart := time.Now()
+	var resp *dns.Msg
+	var err error
+	if e.httpClient != nil {
+		resp, err = e.exchangeHTTPS(msg)
+	} else {
+		resp, err = e.exchangeQUIC(msg)
+	}
+	result := dnsResponse{msg: resp, rtt: time.Since(tStart)}
+	if err != nil {
+		return result, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return result, fmt.Errorf("DNS query error: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return result, nil
+}
+
+// exchangeProxied sends msg over a new TCP connection through the proxy
+func (e *DnsTransport) exchangeProxied(msg *dns.Msg) (dnsResponse, error) {
+	tStart := time.Now()
+	result := dnsResponse{}
+	proxyURL, err := e.proxy.ProxyForAddress(e.Address)
+	if err != nil {
+		return result, err
+	}
+	result.proxyPath = util.ProxyPath(proxyURL)
+	conn, err := util.DialThroughProxy(proxyURL, e.Address, dnsTimeout)
+	if err != nil {
+		result.rtt = time.Since(tStart)
+		return result, err
+	}
+	co := &dns.Conn{Conn: conn}
+	defer co.Close()
+	co.SetDeadline(time.Now().Add(dnsTimeout))
+	if err = co.WriteMsg(msg); err == nil {
+		result.msg, err = co.ReadMsg()
+	}
+	result.rtt = time.Since(tStart)
+	if err != nil {
+		return result, err
+	}
+	if result.msg.Rcode != dns.RcodeSuccess {
+		return result, fmt.Errorf("DNS query error: %s", dns.RcodeToString[result.msg.Rcode])
+	}
+	return result, nil
+}
+
+// exchangeHTTPS sends msg as a DNS over HTTPS GET or POST request
+func (e *DnsTransport) exchangeHTTPS(msg *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 section 4.1: the DNS ID should be 0 so that responses can be cached by HTTP caches
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	if e.Name == "https-get" {
+		req, err = http.NewRequest(http.MethodGet, e.dohURL+"?dns="+base64.RawURLEncoding.EncodeToString(packed), nil)
+	} else {
+		req, err = http.NewRequest(http.MethodPost, e.dohURL, bytes.NewReader(packed))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/dns-message")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS error: %s", resp.Status)
+	}
+
+	reply := &dns.Msg{}
+	if err := reply.Unpack(body); err != nil {
+		return nil, err
+	}
+	reply.Id = msg.Id
+	return reply, nil
+}
+
+// quicConnection returns the QUIC connection to the server, dialling it on first use or after it has been closed
+func (e *DnsTransport) quicConnection() (*quic.Conn, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.quicConn != nil && e.quicConn.Context().Err() == nil {
+		return e.quicConn, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+	conn, err := e.quicDialer.Dial(ctx, e.Address, e.tlsConfig, &quic.Config{HandshakeIdleTimeout: dnsTimeout})
+	if err != nil {
+		return nil, err
+	}
+	e.quicConn = conn
+	return conn, nil
+}
+
+// exchangeQUIC sends msg on a new stream of the DNS over QUIC connection
+func (e *DnsTransport) exchangeQUIC(msg *dns.Msg) (*dns.Msg, error) {
+	conn, err := e.quicConnection()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream.SetDeadline(time.Now().Add(dnsTimeout))
+
+	// RFC 9250 section 4.2.1: the DNS ID must be 0 and messages are prefixed with a 2-byte length
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(buf, uint16(len(packed)))
+	copy(buf[2:], packed)
+	if _, err := stream.Write(buf); err != nil {
+		return nil, err
+	}
+	// one query per stream, so close the sending side
+	stream.Close()
+
+	var length [2]byte
+	if _, err := io.ReadFull(stream, length[:]); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(stream, body); err != nil {
+		return nil, err
+	}
+
+	reply := &dns.Msg{}
+	if err := reply.Unpack(body); err != nil {
+		return nil, err
+	}
+	reply.Id = msg.Id
+	return reply, nil
+}
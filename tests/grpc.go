@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const grpcTimeout = 5 * time.Second
+
+// Methods of the companion server's echo service, which uses the well-known wrapper messages so that no generated code is needed:
+//
+//	service Echo {
+//	  rpc Unary(google.protobuf.BytesValue) returns (google.protobuf.BytesValue);
+//	  rpc Download(google.protobuf.UInt64Value) returns (stream google.protobuf.BytesValue);
+//	  rpc Bidi(stream google.protobuf.BytesValue) returns (stream google.protobuf.BytesValue);
+//	}
+const (
+	grpcUnaryMethod    = "/echo.Echo/Unary"
+	grpcDownloadMethod = "/echo.Echo/Download"
+	grpcBidiMethod     = "/echo.Echo/Bidi"
+)
+
+// NewGrpcConn creates a connection to the echo service at serverHost:serverPort, over TLS trusting our custom CA if isSecure
+func NewGrpcConn(serverHost string, serverPort uint, isSecure bool) (*grpc.ClientConn, error) {
+	transportCredentials := insecure.NewCredentials()
+	if isSecure {
+		tlsConfig := util.CreateTLSConfig()
+		tlsConfig.ServerName = serverHost
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	}
+	return grpc.NewClient(net.JoinHostPort(serverHost, strconv.Itoa(int(serverPort))), grpc.WithTransportCredentials(transportCredentials), grpc.WithNoProxy())
+}
+
+// classifyGrpcFailure returns the failure class of a gRPC call, e.g. "grpc:Unavailable", or the class of a transport error
+func classifyGrpcFailure(err error) string {
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		return "grpc:" + s.Code().String()
+	}
+	return util.ClassifyError(err)
+}
+
+// GrpcUnaryRateTest makes unary echo calls of messageSize bytes at desiredRequestsPerSecond for testDuration
+func GrpcUnaryRateTest(conn *grpc.ClientConn, testDuration time.Duration, desiredRequestsPerSecond int, messageSize uint, processNames []string) model.RateTest {
+	slog.Info(fmt.Sprintf("Making %d gRPC unary calls per second for %s", desiredRequestsPerSecond, testDuration), "target", conn.Target())
+
+	message := wrapperspb.Bytes(bytes.Repeat([]byte{'x'}, int(messageSize)))
+	var mutex sync.Mutex
+	var latencies []time.Duration
+	failures := model.FailureClasses{}
+
+	tStart := time.Now()
+
+	// Start monitoring processes if provided
+	var processUsage model.ProcessCpuAndRam
+	var processWg sync.WaitGroup
+	if len(processNames) > 0 {
+		processWg.Add(1)
+		go func() {
+			defer processWg.Done()
+			processUsage = util.MonitorProcessesContinuously(processNames, testDuration, 100*time.Millisecond)
+		}()
+	}
+
+	countRequests := paceRequests(tStart, testDuration, desiredRequestsPerSecond, func(i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+		defer cancel()
+		reply := &wrapperspb.BytesValue{}
+		tCall := time.Now()
+		err := conn.Invoke(ctx, grpcUnaryMethod, message, reply)
+		latency := time.Since(tCall)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			failures[classifyGrpcFailure(err)]++
+			reportRequest(0, classifyGrpcFailure(err))
+			return
+		}
+		latencies = append(latencies, latency)
+		reportRequest(latency, "")
+	})
+
+	// Wait for process monitoring to complete
+	if len(processNames) > 0 {
+		processWg.Wait()
+	}
+
+	failureRate := 0.0
+	if countRequests > 0 {
+		failureRate = 1.0 - float64(len(latencies))/float64(countRequests)
+	}
+	return model.RateTest{
+		CountRequests:    uint(countRequests),
+		FailureRate:      failureRate,
+		ProcessCpuAndRam: processUsage,
+		Latency:          util.ComputeLatencyStats(latencies),
+		Failures:         failures,
+	}
+}
+
+// GrpcServerStreamTest asks the echo service to stream countBytes bytes and measures the throughput
+func GrpcServerStreamTest(conn *grpc.ClientConn, countBytes uint64, processNames []string) (model.ThroughputTest, error) {
+	slog.Info(fmt.Sprintf("Streaming %.0fMB over gRPC", float64(countBytes)/1e6), "target", conn.Target())
+	result := model.ThroughputTest{Type: model.RX, Protocol: "gRPC"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, grpcDownloadMethod)
+	if err != nil {
+		return result, err
+	}
+
+	// Start monitoring processes if provided
+	var processMonitoringDone sync.WaitGroup
+	var processUsage model.ProcessCpuAndRam
+	if len(processNames) > 0 {
+		processMonitoringDone.Add(1)
+		go func() {
+			defer processMonitoringDone.Done()
+			// Monitor for a reasonable throughput test duration (10 seconds should be enough)
+			processUsage = util.MonitorProcessesContinuously(processNames, 10*time.Second, 100*time.Millisecond)
+		}()
+	}
+
+	tStart := time.Now()
+	if err = stream.SendMsg(wrapperspb.UInt64(countBytes)); err == nil {
+		err = stream.CloseSend()
+	}
+	for err == nil {
+		chunk := &wrapperspb.BytesValue{}
+		if err = stream.RecvMsg(chunk); err == nil {
+			result.CountBytesTransferred += uint64(len(chunk.Value))
+			reportTransferred(len(chunk.Value))
+		}
+	}
+	result.DurationNanoseconds = uint64(time.Since(tStart).Nanoseconds())
+
+	// Stop process monitoring
+	if len(processNames) > 0 {
+		processMonitoringDone.Wait()
+	}
+	result.ProcessCpuAndRam = processUsage
+
+	if err != io.EOF {
+		return result, err
+	}
+	return result, nil
+}
+
+// GrpcBidiLatencyTest sends countMessages messages of messageSize bytes one at a time on a bidirectional stream
+// and times each echo
+func GrpcBidiLatencyTest(conn *grpc.ClientConn, countMessages uint, messageSize uint) (model.PingTest, model.FailureClasses) {
+	slog.Info(fmt.Sprintf("Echoing %d gRPC stream messages", countMessages), "target", conn.Target())
+	failures := model.FailureClasses{}
+	samples := make([]model.PingSample, 0, countMessages)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, grpcBidiMethod)
+	message := wrapperspb.Bytes(bytes.Repeat([]byte{'x'}, int(messageSize)))
+	for i := uint(0); i < countMessages; i++ {
+		sample := model.PingSample{Sequence: i}
+		if err == nil {
+			tStart := time.Now()
+			if err = stream.SendMsg(message); err == nil {
+				err = stream.RecvMsg(&wrapperspb.BytesValue{})
+			}
+			sample.RTT = time.Since(tStart)
+		}
+		if err != nil {
+			// a failed stream cannot be used again, so the remaining messages are lost
+			if i == 0 || !samples[i-1].Lost {
+				failures[classifyGrpcFailure(err)]++
+			}
+			sample.RTT = 0
+			sample.Lost = true
+		}
+		samples = append(samples, sample)
+		reportSample(sample.RTT, sample.Lost)
+	}
+	if err == nil {
+		stream.CloseSend()
+	}
+	return model.PingTest{Samples: samples, Stats: util.ComputePingStats(samples)}, failures
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+// httpProtocolName returns the name of the protocol used by a test for its progress output, e.g. "HTTPS (h2)"
+func httpProtocolName(isHttps bool, clientOptions util.HTTPClientOptions) string {
+	switch {
+	case clientOptions.Protocol == "h3":
+		return "HTTP/3"
+	case clientOptions.Protocol != "" && isHttps:
+		return "HTTPS (" + clientOptions.Protocol + ")"
+	case clientOptions.Protocol != "":
+		return "HTTP (" + clientOptions.Protocol + ")"
+	case isHttps:
+		return "HTTPS"
+	default:
+		return "HTTP"
+	}
+}
+
+// httpResults collects the outcome of the requests made by a burst or rate test
+type httpResults struct {
+	mutex                  sync.Mutex
+	countResponses         uint
+	countNewConnections    uint
+	countReusedConnections uint
+	latencies              []time.Duration
+	protocols              map[string]uint
+	failures               model.FailureClasses
+}
+
+func newHttpResults() *httpResults {
+	return &httpResults{
+		protocols: map[string]uint{},
+		failures:  model.FailureClasses{},
+	}
+}
+
+// get requests url, reading the whole response body, and records the outcome and whether a connection was reused
+func (e *httpResults) get(client *http.Client, url string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		e.mutex.Lock()
+		e.failures[util.ClassifyError(err)]++
+		e.mutex.Unlock()
+		reportRequest(0, util.ClassifyError(err))
+		return
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			e.mutex.Lock()
+			defer e.mutex.Unlock()
+			if info.Reused {
+				e.countReusedConnections++
+			} else {
+				e.countNewConnections++
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	tStart := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	latency := time.Since(tStart)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err != nil {
+		e.failures[util.ClassifyError(err)]++
+		reportRequest(0, util.ClassifyError(err))
+		return
+	}
+	reportRequest(latency, "")
+	e.countResponses++
+	e.latencies = append(e.latencies, latency)
+	e.protocols[resp.Proto]++
+}
+
+// prewarmConnections opens up to count connections to the server of url, which stay idle for the test to reuse
+// if the client keeps enough idle connections per host
+func prewarmConnections(client *http.Client, url string, count uint) {
+	if count == 0 {
+		return
+	}
+	slog.Info(fmt.Sprintf("Pre-warming %d connections", count), "url", url)
+	var wg sync.WaitGroup
+	for i := uint(0); i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				return
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func (e *httpResults) failureRate(countRequests int) float64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return math.Max(0, 1.0-float64(e.countResponses)/float64(countRequests))
+}
+
+func (e *httpResults) latency() model.LatencyStats {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return util.ComputeLatencyStats(e.latencies)
+}
@@ -2,7 +2,7 @@ package tests
 
 import (
 	"fmt"
-	"math"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -10,19 +10,14 @@ import (
 	"github.com/jrcamenzuli/network-performance-tester-client/util"
 )
 
-func HttpBurstTest(url string, burstSize int, pid uint, isHttps bool, processNames []string) model.BurstTest {
-	protocol := ""
-	if isHttps {
-		protocol = "HTTPS"
-	} else {
-		protocol = "HTTP"
-	}
-	countRequests := 0
-	countResponses := 0
+func HttpBurstTest(url string, burstSize int, pid uint, isHttps bool, processNames []string, clientOptions util.HTTPClientOptions) model.BurstTest {
+	protocol := httpProtocolName(isHttps, clientOptions)
+	results := newHttpResults()
 	var wg sync.WaitGroup
 
-	fmt.Printf("Sending a burst of %d %s requests to %s\n", burstSize, protocol, url)
-	client := util.CreateHTTPSClient()
+	slog.Info(fmt.Sprintf("Sending a burst of %d %s requests", burstSize, protocol), "url", url)
+	client := util.CreateHTTPClient(clientOptions)
+	prewarmConnections(client, url, clientOptions.PrewarmConnections)
 
 	tStart := time.Now()
 
@@ -42,14 +37,7 @@ func HttpBurstTest(url string, burstSize int, pid uint, isHttps bool, processNam
 		wg.Add(1)
 		go func(wg *sync.WaitGroup) {
 			defer wg.Done()
-			countRequests++
-			resp, err := client.Get(url)
-			if err == nil {
-				countResponses++
-				defer resp.Body.Close()
-			} else {
-				return
-			}
+			results.get(client, url)
 		}(&wg)
 	}
 
@@ -64,11 +52,20 @@ func HttpBurstTest(url string, burstSize int, pid uint, isHttps bool, processNam
 		processMonitoringDone.Wait()
 	}
 
-	failureRate := math.Max(0, 1.0-float64(countResponses)/float64(countRequests))
+	countFallbacks, fallbackCost := util.HTTPClientFallbacks(client)
 	return model.BurstTest{
-		Duration:         duration,
-		FailureRate:      failureRate,
-		CpuAndRam:        cpuAndRam,
-		ProcessCpuAndRam: processUsage,
+		Duration:               duration,
+		CountRequests:          uint(burstSize),
+		FailureRate:            results.failureRate(burstSize),
+		CpuAndRam:              cpuAndRam,
+		ProcessCpuAndRam:       processUsage,
+		Latency:                results.latency(),
+		Protocols:              results.protocols,
+		Failures:               results.failures,
+		CountFallbacks:         countFallbacks,
+		FallbackCost:           fallbackCost,
+		CountNewConnections:    results.countNewConnections,
+		CountReusedConnections: results.countReusedConnections,
+		ProxyPaths:             util.HTTPClientProxyPaths(client),
 	}
 }
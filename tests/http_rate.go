@@ -2,7 +2,7 @@ package tests
 
 import (
 	"fmt"
-	"math"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -10,34 +10,20 @@ import (
 	"github.com/jrcamenzuli/network-performance-tester-client/util"
 )
 
-func HttpRateTest(url string, testDuration time.Duration, desiredRequestsPerSecond int, pid uint, isHttps bool, processNames []string) model.RateTest {
-	protocol := ""
-	if isHttps {
-		protocol = "HTTPS"
-	} else {
-		protocol = "HTTP"
-	}
+func HttpRateTest(url string, testDuration time.Duration, desiredRequestsPerSecond int, pid uint, isHttps bool, processNames []string, clientOptions util.HTTPClientOptions) model.RateTest {
+	protocol := httpProtocolName(isHttps, clientOptions)
 
-	fmt.Printf("Sending %d %s requests per second for %s to %s\n", desiredRequestsPerSecond, protocol, testDuration, url)
+	slog.Info(fmt.Sprintf("Sending %d %s requests per second for %s", desiredRequestsPerSecond, protocol, testDuration), "url", url)
 
-	client := util.CreateHTTPSClient()
-	countRequests := 0
-	countResponses := 0
+	client := util.CreateHTTPClient(clientOptions)
+	prewarmConnections(client, url, clientOptions.PrewarmConnections)
+	results := newHttpResults()
 	countSamples := 0
-	var duration time.Duration
 	cpuAndRam := model.CpuAndRam{Pid: pid}
-	Kp := 2.0
-	Ki := 1.2
-	Kd := 0.001
-	integral := 0.0
-	previous_error := 0.0
-
-	sumActualRequestsPerSecond := 0.0
 
 	var wg sync.WaitGroup
 
 	tStart := time.Now()
-	tLast := tStart
 
 	// Start monitoring processes if provided
 	var processUsage model.ProcessCpuAndRam
@@ -64,45 +50,9 @@ func HttpRateTest(url string, testDuration time.Duration, desiredRequestsPerSeco
 		cpuAndRam.Ram = util.GetCPUandRAM(pid).Ram
 	}(&wg)
 
-	time.Sleep(time.Duration(1.0 / float64(desiredRequestsPerSecond) * float64(time.Second)))
-	for {
-
-		duration = time.Since(tStart)
-		if duration >= testDuration {
-			break
-		}
-		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
-			resp, err := client.Get(url)
-			// fmt.Println("boop")
-			if err == nil {
-				countResponses++
-				defer resp.Body.Close()
-			} else {
-				return
-			}
-		}(&wg)
-		countRequests++
-
-		dt := time.Since(tLast)
-		tLast = time.Now()
-		sumActualRequestsPerSecond += float64(countRequests) / float64(duration.Seconds())
-		averageActualRequestsPerSecond := sumActualRequestsPerSecond / float64(countRequests)
-
-		error_ := 1.0/float64(desiredRequestsPerSecond) - 1.0/averageActualRequestsPerSecond
-		proportional := error_
-		integral = integral + error_*dt.Seconds()
-		derivative := (error_ - previous_error) / dt.Seconds()
-		output := Kp*proportional + Ki*integral + Kd*derivative
-		if output == math.NaN() || math.IsInf(output, 0) {
-			output = 0.0
-		}
-		previous_error = error_
-
-		fmt.Printf("desiredRequestsPerSecond:%d, averageActualRequestsPerSecond:%f, output:%f\n", desiredRequestsPerSecond, averageActualRequestsPerSecond, output)
-		time.Sleep(time.Duration(1.0/float64(desiredRequestsPerSecond)*float64(time.Second)) + time.Duration(output*float64(time.Second)))
-	}
+	countRequests := paceRequests(tStart, testDuration, desiredRequestsPerSecond, func(i int) {
+		results.get(client, url)
+	})
 
 	wg.Wait()
 
@@ -111,10 +61,19 @@ func HttpRateTest(url string, testDuration time.Duration, desiredRequestsPerSeco
 		processWg.Wait()
 	}
 
-	failureRate := math.Max(0, 1.0-float64(countResponses)/float64(countRequests))
+	countFallbacks, fallbackCost := util.HTTPClientFallbacks(client)
 	return model.RateTest{
-		FailureRate:      failureRate,
-		CpuAndRam:        cpuAndRam,
-		ProcessCpuAndRam: processUsage,
+		CountRequests:          uint(countRequests),
+		FailureRate:            results.failureRate(countRequests),
+		CpuAndRam:              cpuAndRam,
+		ProcessCpuAndRam:       processUsage,
+		Latency:                results.latency(),
+		Protocols:              results.protocols,
+		Failures:               results.failures,
+		CountFallbacks:         countFallbacks,
+		FallbackCost:           fallbackCost,
+		CountNewConnections:    results.countNewConnections,
+		CountReusedConnections: results.countReusedConnections,
+		ProxyPaths:             util.HTTPClientProxyPaths(client),
 	}
 }
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// icmpSocket is an ICMP socket and how to address and parse packets on it
+type icmpSocket struct {
+	conn        *icmp.PacketConn
+	destination net.Addr
+	protocol    int
+	echoRequest icmp.Type
+	echoReply   icmp.Type
+	privileged  bool
+}
+
+// openIcmpSocket opens an unprivileged datagram ICMP socket, falling back to a raw socket when that is not permitted
+func openIcmpSocket(ip *net.IPAddr) (*icmpSocket, error) {
+	socket := &icmpSocket{}
+	var networks []string
+	var address string
+	if ip.IP.To4() != nil {
+		networks = []string{"udp4", "ip4:icmp"}
+		address = "0.0.0.0"
+		socket.protocol = protocolICMP
+		socket.echoRequest = ipv4.ICMPTypeEcho
+		socket.echoReply = ipv4.ICMPTypeEchoReply
+	} else {
+		networks = []string{"udp6", "ip6:ipv6-icmp"}
+		address = "::"
+		socket.protocol = protocolIPv6ICMP
+		socket.echoRequest = ipv6.ICMPTypeEchoRequest
+		socket.echoReply = ipv6.ICMPTypeEchoReply
+	}
+
+	var errs []string
+	for _, network := range networks {
+		conn, err := icmp.ListenPacket(network, address)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", network, err))
+			continue
+		}
+		socket.conn = conn
+		socket.privileged = network != networks[0]
+		if socket.privileged {
+			socket.destination = ip
+		} else {
+			socket.destination = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+		}
+		return socket, nil
+	}
+	return nil, fmt.Errorf("could not open an ICMP socket (%s)", strings.Join(errs, "; "))
+}
+
+// ping sends a single echo request and waits for the matching echo reply
+func (e *icmpSocket) ping(id int, sequence int) (time.Duration, error) {
+	request := icmp.Message{
+		Type: e.echoRequest,
+		Body: &icmp.Echo{ID: id, Seq: sequence, Data: []byte("network-performance-tester")},
+	}
+	b, err := request.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(pingTimeout)
+	e.conn.SetReadDeadline(deadline)
+	tStart := time.Now()
+	if _, err := e.conn.WriteTo(b, e.destination); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := e.conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		tStop := time.Now()
+		reply, err := icmp.ParseMessage(e.protocol, buf[:n])
+		if err != nil || reply.Type != e.echoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		// the kernel rewrites the identifier of datagram sockets, so it can only be checked on raw sockets
+		if !ok || echo.Seq != sequence || (e.privileged && echo.ID != id) {
+			continue
+		}
+		return tStop.Sub(tStart), nil
+	}
+}
+
+// IcmpPingTest sends countSamples ICMP echo requests to host and returns every sample together with the RTT statistics
+func IcmpPingTest(host string, countSamples uint) (model.PingTest, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return model.PingTest{}, err
+	}
+	socket, err := openIcmpSocket(ip)
+	if err != nil {
+		return model.PingTest{}, err
+	}
+	defer socket.conn.Close()
+
+	socketType := "datagram"
+	if socket.privileged {
+		socketType = "raw"
+	}
+	slog.Info(fmt.Sprintf("Sending %d ICMP echo requests over a %s socket", countSamples, socketType), "ip", ip)
+
+	id := os.Getpid() & 0xffff
+	samples := make([]model.PingSample, 0, countSamples)
+	for i := uint(0); i < countSamples; i++ {
+		dt, err := socket.ping(id, int(i&0xffff))
+		samples = append(samples, model.PingSample{Sequence: i, RTT: dt, Lost: err != nil})
+		reportSample(dt, err != nil)
+	}
+	return model.PingTest{
+		Samples: samples,
+		Stats:   util.ComputePingStats(samples),
+	}, nil
+}
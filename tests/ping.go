@@ -1,19 +1,56 @@
 package tests
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
 	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
 )
 
-func Ping(conn net.Conn) time.Duration {
+const pingTimeout = time.Second
+
+// Ping sends a 4 byte sequence number to an echo server and waits for the same sequence number to be echoed back
+func Ping(conn net.Conn, sequence uint32) (time.Duration, error) {
+	conn.SetReadDeadline(time.Now().Add(pingTimeout))
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, sequence)
 	tStart := time.Now()
-	conn.Write([]byte{0})
-	n := 0
-	for n < 1 {
-		n, _ = conn.Read([]byte{0})
-		// fmt.Print(n)
+	if _, err := conn.Write(buf); err != nil {
+		return 0, err
+	}
+	for {
+		n, err := io.ReadFull(conn, buf)
+		if err != nil {
+			if n > 0 {
+				// the rest of a stream's echo may still arrive, which would misalign every later echo
+				return 0, fmt.Errorf("incomplete echo of %d bytes: %v", n, err)
+			}
+			return 0, err
+		}
+		// discard late echoes of earlier pings that timed out
+		if binary.BigEndian.Uint32(buf) == sequence {
+			break
+		}
 	}
 	tStop := time.Now()
 	dt := tStop.Sub(tStart)
-	return dt
+	return dt, nil
+}
+
+// PingTest sends countSamples consecutive pings and returns every sample together with the RTT statistics
+func PingTest(conn net.Conn, countSamples uint) model.PingTest {
+	samples := make([]model.PingSample, 0, countSamples)
+	for i := uint(0); i < countSamples; i++ {
+		dt, err := Ping(conn, uint32(i))
+		samples = append(samples, model.PingSample{Sequence: i, RTT: dt, Lost: err != nil})
+		reportSample(dt, err != nil)
+	}
+	return model.PingTest{
+		Samples: samples,
+		Stats:   util.ComputePingStats(samples),
+	}
 }
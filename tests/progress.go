@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ProgressObserver is told the outcome of every request, query, call, echo and transfer as it completes, e.g. to show
+// live progress. It is called from many goroutines at once
+type ProgressObserver interface {
+	Request(latency time.Duration, failure string) // failure is the failure class, e.g. "timeout", or empty on success
+	Transferred(countBytes int)
+}
+
+// progress observes the tests in progress, none if nil
+var progress atomic.Pointer[ProgressObserver]
+
+// SetProgress sets the observer of the tests in progress, none if observer is nil. It can be called while tests run
+func SetProgress(observer ProgressObserver) {
+	if observer == nil {
+		progress.Store(nil)
+		return
+	}
+	progress.Store(&observer)
+}
+
+func reportRequest(latency time.Duration, failure string) {
+	if observer := progress.Load(); observer != nil {
+		(*observer).Request(latency, failure)
+	}
+}
+
+func reportTransferred(countBytes int) {
+	if observer := progress.Load(); observer != nil && countBytes > 0 {
+		(*observer).Transferred(countBytes)
+	}
+}
+
+// reportSample reports a ping sample as a request that failed with "lost" if it was lost
+func reportSample(rtt time.Duration, lost bool) {
+	if lost {
+		reportRequest(0, "lost")
+	} else {
+		reportRequest(rtt, "")
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// paceRequests calls send in a new goroutine desiredRequestsPerSecond times per second until testDuration has passed since tStart,
+// using a PID controller to correct the sleep between requests. It waits for every send to return and returns the number of requests sent.
+func paceRequests(tStart time.Time, testDuration time.Duration, desiredRequestsPerSecond int, send func(i int)) int {
+	var wg sync.WaitGroup
+	countRequests := 0
+	Kp := 2.0
+	Ki := 1.2
+	Kd := 0.001
+	integral := 0.0
+	previous_error := 0.0
+
+	sumActualRequestsPerSecond := 0.0
+	tLast := tStart
+
+	time.Sleep(time.Duration(1.0 / float64(desiredRequestsPerSecond) * float64(time.Second)))
+	for {
+
+		duration := time.Since(tStart)
+		if duration >= testDuration {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			send(i)
+		}(countRequests)
+		countRequests++
+
+		dt := time.Since(tLast)
+		tLast = time.Now()
+		sumActualRequestsPerSecond += float64(countRequests) / float64(duration.Seconds())
+		averageActualRequestsPerSecond := sumActualRequestsPerSecond / float64(countRequests)
+
+		error_ := 1.0/float64(desiredRequestsPerSecond) - 1.0/averageActualRequestsPerSecond
+		proportional := error_
+		integral = integral + error_*dt.Seconds()
+		derivative := (error_ - previous_error) / dt.Seconds()
+		output := Kp*proportional + Ki*integral + Kd*derivative
+		if math.IsNaN(output) || math.IsInf(output, 0) {
+			output = 0.0
+		}
+		previous_error = error_
+
+		time.Sleep(time.Duration(1.0/float64(desiredRequestsPerSecond)*float64(time.Second)) + time.Duration(output*float64(time.Second)))
+	}
+	wg.Wait()
+	return countRequests
+}
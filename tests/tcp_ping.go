@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+// TcpConnectPingTest times countSamples TCP connection attempts to address.
+// The time taken by net.Dial is one SYN/SYN-ACK round trip, so it is comparable to a ping on any listening port.
+func TcpConnectPingTest(address string, countSamples uint) model.PingTest {
+	slog.Info(fmt.Sprintf("Timing %d TCP connections", countSamples), "address", address)
+	samples := make([]model.PingSample, 0, countSamples)
+	for i := uint(0); i < countSamples; i++ {
+		tStart := time.Now()
+		conn, err := net.DialTimeout("tcp", address, pingTimeout)
+		tStop := time.Now()
+		if err != nil {
+			samples = append(samples, model.PingSample{Sequence: i, Lost: true})
+			reportSample(0, true)
+			continue
+		}
+		conn.Close()
+		samples = append(samples, model.PingSample{Sequence: i, RTT: tStop.Sub(tStart)})
+		reportSample(tStop.Sub(tStart), false)
+	}
+	return model.PingTest{
+		Samples: samples,
+		Stats:   util.ComputePingStats(samples),
+	}
+}
+
+// TcpEchoPingTest sends countSamples echoes of a 4-byte sequence number over a persistent TCP connection to the echo
+// server at address.
+// The connection is re-established if it breaks, so a single failure does not lose the remaining samples.
+func TcpEchoPingTest(address string, countSamples uint) (model.PingTest, error) {
+	slog.Info(fmt.Sprintf("Sending %d TCP echoes", countSamples), "address", address)
+	conn, err := net.DialTimeout("tcp", address, pingTimeout)
+	if err != nil {
+		return model.PingTest{}, err
+	}
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	samples := make([]model.PingSample, 0, countSamples)
+	for i := uint(0); i < countSamples; i++ {
+		if conn == nil {
+			if conn, err = net.DialTimeout("tcp", address, pingTimeout); err != nil {
+				conn = nil
+				samples = append(samples, model.PingSample{Sequence: i, Lost: true})
+				reportSample(0, true)
+				continue
+			}
+		}
+		dt, err := Ping(conn, uint32(i))
+		if err != nil {
+			// a timed out echo may still arrive later and is discarded by Ping, anything else breaks the connection
+			if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+				conn.Close()
+				conn = nil
+			}
+			samples = append(samples, model.PingSample{Sequence: i, Lost: true})
+			reportSample(0, true)
+			continue
+		}
+		samples = append(samples, model.PingSample{Sequence: i, RTT: dt})
+		reportSample(dt, false)
+	}
+	return model.PingTest{
+		Samples: samples,
+		Stats:   util.ComputePingStats(samples),
+	}, nil
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"sync"
@@ -17,10 +16,10 @@ import (
 const chunkSize = 10000000
 const countBytesTransfer = 100000000 // 100MB
 
-func DownloadThroughputTest(serverProtocol string, serverHost string, serverPort uint, pid uint, processNames []string) (model.ThroughputTest, error) {
+func DownloadThroughputTest(serverProtocol string, serverHost string, serverPort uint, pid uint, processNames []string, clientOptions util.HTTPClientOptions) (model.ThroughputTest, error) {
 	url := fmt.Sprintf("%s%s:%d/download/%d", serverProtocol, serverHost, serverPort, countBytesTransfer)
 
-	client := util.CreateHTTPSClient()
+	client := util.CreateHTTPClient(clientOptions)
 	resp, err := client.Get(url)
 	if err == nil {
 		defer resp.Body.Close()
@@ -47,6 +46,7 @@ func DownloadThroughputTest(serverProtocol string, serverHost string, serverPort
 	for countBytesToReceive > 0 {
 		countBytesRead, err := resp.Body.Read(bytes)
 		countBytesToReceive -= countBytesRead
+		reportTransferred(countBytesRead)
 		if err != nil {
 			break
 		}
@@ -61,16 +61,21 @@ func DownloadThroughputTest(serverProtocol string, serverHost string, serverPort
 	}
 
 	cpuAndRam := util.GetCPUandRAM(pid)
+	countFallbacks, fallbackCost := util.HTTPClientFallbacks(client)
 	return model.ThroughputTest{
 		Type:                  model.RX,
 		CountBytesTransferred: countBytesTransferred,
 		DurationNanoseconds:   uint64(dt.Nanoseconds()),
 		CpuAndRam:             *cpuAndRam,
 		ProcessCpuAndRam:      processUsage,
+		Protocol:              resp.Proto,
+		CountFallbacks:        countFallbacks,
+		FallbackCost:          fallbackCost,
+		ProxyPaths:            util.HTTPClientProxyPaths(client),
 	}, nil
 }
 
-func UploadThroughputTest(serverProtocol string, serverHost string, serverPort uint, pid uint, processNames []string) (model.ThroughputTest, error) {
+func UploadThroughputTest(serverProtocol string, serverHost string, serverPort uint, pid uint, processNames []string, clientOptions util.HTTPClientOptions) (model.ThroughputTest, error) {
 	url := fmt.Sprintf("%s%s:%d/upload", serverProtocol, serverHost, serverPort)
 	countBytesToSend := countBytesTransfer
 	var tStart time.Time
@@ -130,11 +135,13 @@ func UploadThroughputTest(serverProtocol string, serverHost string, serverPort u
 				n, _ := wr.Write(buff[:countBytesToSend])
 				countBytesToSend -= n
 				countBytesSent += n
+				reportTransferred(n)
 				break
 			} else {
 				n, err := wr.Write(buff)
 				countBytesToSend -= n
 				countBytesSent += n
+				reportTransferred(n)
 				if err != nil {
 					break
 				}
@@ -150,15 +157,21 @@ func UploadThroughputTest(serverProtocol string, serverHost string, serverPort u
 	req.ContentLength = totalSize
 
 	//process request
-	client := util.CreateHTTPSClient()
+	client := util.CreateHTTPClient(clientOptions)
+	if clientOptions.Protocol == "h3" {
+		// the streamed upload cannot be retried, so find out whether QUIC works before sending it
+		if probe, err := client.Get(fmt.Sprintf("%s%s:%d/download/1", serverProtocol, serverHost, serverPort)); err == nil {
+			probe.Body.Close()
+		}
+	}
 	tStart = time.Now()
 	resp, err := client.Do(req)
 	tStop = time.Now()
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		resp.Body.Close()
+		return model.ThroughputTest{Type: model.TX}, err
 	}
+	resp.Body.Close()
+	countFallbacks, fallbackCost := util.HTTPClientFallbacks(client)
 
 	dt := tStop.Sub(tStart)
 
@@ -174,5 +187,9 @@ func UploadThroughputTest(serverProtocol string, serverHost string, serverPort u
 		DurationNanoseconds:   uint64(dt.Nanoseconds()),
 		CpuAndRam:             *cpuAndRam,
 		ProcessCpuAndRam:      processUsage,
+		Protocol:              resp.Proto,
+		CountFallbacks:        countFallbacks,
+		FallbackCost:          fallbackCost,
+		ProxyPaths:            util.HTTPClientProxyPaths(client),
 	}, nil
 }
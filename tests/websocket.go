@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+	"github.com/jrcamenzuli/network-performance-tester-client/util"
+)
+
+const webSocketTimeout = 5 * time.Second
+
+// webSocketWindow is the number of messages that may be awaiting their echo during the message rate phase
+const webSocketWindow = 64
+
+// WebSocketTest connects to the WebSocket echo endpoint at url and measures the upgrade latency, the RTT of countMessages
+// sequential messages of messageSize bytes and then the sustained message rate over testDuration
+func WebSocketTest(url string, messageSize uint, countMessages uint, testDuration time.Duration, proxy *util.ProxySelector, processNames []string) (model.WebSocketTest, error) {
+	slog.Info(fmt.Sprintf("Exchanging %d byte WebSocket messages", messageSize), "url", url)
+
+	result := model.WebSocketTest{
+		MessageSize: messageSize,
+		Failures:    model.FailureClasses{},
+	}
+	dialer := websocket.Dialer{
+		TLSClientConfig:  util.CreateTLSConfig(),
+		HandshakeTimeout: webSocketTimeout,
+		Proxy: func(req *http.Request) (*neturl.URL, error) {
+			proxyURL, err := proxy.ProxyForRequest(req)
+			result.ProxyPath = util.ProxyPath(proxyURL)
+			return proxyURL, err
+		},
+	}
+	tStart := time.Now()
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+	result.UpgradeLatency = time.Since(tStart)
+
+	message := bytes.Repeat([]byte{'x'}, int(messageSize))
+
+	// RTT of one message at a time
+	var rtts []time.Duration
+	for i := uint(0); i < countMessages; i++ {
+		rtt, err := webSocketEcho(conn, message)
+		if err != nil {
+			// a WebSocket connection cannot be used after a failed read or write
+			result.Failures[util.ClassifyError(err)]++
+			reportRequest(0, util.ClassifyError(err))
+			return result, err
+		}
+		rtts = append(rtts, rtt)
+		reportRequest(rtt, "")
+	}
+	result.Latency = util.ComputeLatencyStats(rtts)
+
+	// Start monitoring processes if provided
+	var processMonitoringDone sync.WaitGroup
+	var processUsage model.ProcessCpuAndRam
+	if len(processNames) > 0 {
+		processMonitoringDone.Add(1)
+		go func() {
+			defer processMonitoringDone.Done()
+			processUsage = util.MonitorProcessesContinuously(processNames, testDuration, 100*time.Millisecond)
+		}()
+	}
+
+	// Sustained message rate, keeping up to webSocketWindow messages in flight
+	window := make(chan struct{}, webSocketWindow)
+	received := make(chan uint)
+	tStart = time.Now()
+	deadline := tStart.Add(testDuration)
+	go func() {
+		countReceived := uint(0)
+		for {
+			conn.SetReadDeadline(time.Now().Add(webSocketTimeout))
+			_, _, err := conn.ReadMessage()
+			if err != nil || time.Now().After(deadline) {
+				received <- countReceived
+				return
+			}
+			countReceived++
+			<-window
+		}
+	}()
+	for time.Now().Before(deadline) {
+		select {
+		case window <- struct{}{}:
+		case <-time.After(time.Until(deadline)):
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(webSocketTimeout))
+		if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+			result.Failures[util.ClassifyError(err)]++
+			break
+		}
+		result.CountMessagesSent++
+	}
+	result.CountMessagesReceived = <-received
+	result.Duration = testDuration
+	result.MessagesPerSecond = float64(result.CountMessagesReceived) / testDuration.Seconds()
+
+	// Stop process monitoring
+	if len(processNames) > 0 {
+		processMonitoringDone.Wait()
+	}
+	result.ProcessCpuAndRam = processUsage
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return result, nil
+}
+
+// webSocketEcho sends message and waits for it to be echoed back
+func webSocketEcho(conn *websocket.Conn, message []byte) (time.Duration, error) {
+	tStart := time.Now()
+	conn.SetWriteDeadline(tStart.Add(webSocketTimeout))
+	if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+		return 0, err
+	}
+	conn.SetReadDeadline(tStart.Add(webSocketTimeout))
+	_, reply, err := conn.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(tStart)
+	if len(reply) != len(message) {
+		return 0, fmt.Errorf("WebSocket echo of %d bytes, expected %d", len(reply), len(message))
+	}
+	return rtt, nil
+}
@@ -12,7 +12,52 @@ type Configuration struct {
 		ServerTCP_HTTP_Port  uint     `yaml:"server_tcp_http_port"`
 		ServerTCP_HTTPS_Port uint     `yaml:"server_tcp_https_port"`
 		ServerTCP_DNS_Port   uint     `yaml:"server_tcp_dns_port"`
-		Tests                struct {
+		ServerTCP_EchoPort   uint     `yaml:"server_tcp_echo_port"`
+		ServerTCP_DoT_Port   uint     `yaml:"server_tcp_dot_port"`
+		ServerTCP_DoH_Port   uint     `yaml:"server_tcp_doh_port"`
+		ServerUDP_DoQ_Port   uint     `yaml:"server_udp_doq_port"`
+		ServerTCP_GRPC_Port  uint     `yaml:"server_tcp_grpc_port"`
+		DNS                  struct {
+			QueryName      string   `yaml:"query_name"`
+			QueryTypes     []string `yaml:"query_types"`      // record types the burst and rate tests cycle through
+			NameMode       string   `yaml:"name_mode"`        // fixed, random or list
+			DomainListFile string   `yaml:"domain_list_file"` // names to query in list mode, one per line
+			RevisitRatio   float64  `yaml:"revisit_ratio"`    // fraction of random or list queries that repeat an earlier name
+			DoH_Path       string   `yaml:"doh_path"`         // URL path of the DNS over HTTPS endpoint
+		} `yaml:"dns"`
+		Proxy              ProxyConfig         `yaml:"proxy"`
+		LocalAddress       string              `yaml:"local_address"`       // IP address the tests connect from, to select an interface
+		StabilityThreshold float64             `yaml:"stability_threshold"` // coefficient of variation in percent of repeated steps above which a warning is printed, 10 if 0
+		Logging            LoggingConfig       `yaml:"logging"`
+		Dashboard          bool                `yaml:"dashboard"`  // show a live dashboard of the run in progress when the output is a terminal, and progress lines when it is not
+		Assertions         map[string][]string `yaml:"assertions"` // per test name, e.g. https_burst, checks such as "p99_latency_ms < 20" of every step
+		Metrics            struct {
+			Listen  string `yaml:"listen"`   // address of the Prometheus /metrics endpoint, e.g. ":9464", none if empty
+			PushURL string `yaml:"push_url"` // Pushgateway the final metrics are pushed to, e.g. "http://pushgateway:9091", none if empty
+			Job     string `yaml:"job"`      // job of the pushed metrics, network_performance_tester if empty
+		} `yaml:"metrics"`
+		Exporters struct {
+			InfluxDB struct {
+				File  string `yaml:"file"`  // line protocol file in the run's directory, none if empty
+				URL   string `yaml:"url"`   // write endpoint, e.g. "http://influxdb:8086/api/v2/write?org=perf&bucket=network&precision=ns", none if empty
+				Token string `yaml:"token"` // sent as "Authorization: Token <token>" if set
+			} `yaml:"influxdb"`
+			OTLP struct {
+				Endpoint string            `yaml:"endpoint"` // OTLP/HTTP metrics endpoint, e.g. "http://collector:4318/v1/metrics", none if empty
+				Headers  map[string]string `yaml:"headers"`  // e.g. for authentication
+			} `yaml:"otlp"`
+		} `yaml:"exporters"`
+		Store struct {
+			Disable bool   `yaml:"disable"` // do not record the run in the SQLite store
+			Path    string `yaml:"path"`    // SQLite database of every run, test-results/results.db if empty
+		} `yaml:"store"`
+		Comparison struct {
+			Enable bool           `yaml:"enable"`
+			Rest   uint           `yaml:"rest"` // seconds between runs, 5 if 0
+			A      ComparisonPath `yaml:"a"`
+			B      ComparisonPath `yaml:"b"`
+		} `yaml:"comparison"`
+		Tests struct {
 			IdleStateOfDevice struct {
 				Enable bool `yaml:"enable"`
 			} `yaml:"idle_state_of_device"`
@@ -20,57 +65,219 @@ type Configuration struct {
 				Enable bool `yaml:"enable"`
 			} `yaml:"idle_state_of_process"`
 			HTTP_Burst struct {
-				Enable bool `yaml:"enable"`
+				Enable           bool              `yaml:"enable"`
+				Repetitions      uint              `yaml:"repetitions"` // runs of each step, 1 if 0
+				Protocol         string            `yaml:"protocol"`    // http1, http1-close or h2, Go's defaults if empty
+				MaxStreams       uint              `yaml:"max_streams"` // concurrent HTTP/2 streams
+				ConnectionPolicy ConnectionPolicy  `yaml:"connection_policy"`
+				ComparePolicy    *ConnectionPolicy `yaml:"compare_policy"` // if set, every step is also run under this policy and the deltas are reported
 			} `yaml:"http_burst"`
 			HTTPS_Burst struct {
-				Enable bool `yaml:"enable"`
+				Enable           bool              `yaml:"enable"`
+				Repetitions      uint              `yaml:"repetitions"` // runs of each step, 1 if 0
+				Protocol         string            `yaml:"protocol"`    // http1, http1-close or h2, Go's defaults if empty
+				MaxStreams       uint              `yaml:"max_streams"` // concurrent HTTP/2 streams
+				ConnectionPolicy ConnectionPolicy  `yaml:"connection_policy"`
+				ComparePolicy    *ConnectionPolicy `yaml:"compare_policy"` // if set, every step is also run under this policy and the deltas are reported
 			} `yaml:"https_burst"`
+			HTTP3_Burst struct {
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
+				Fallback    bool `yaml:"fallback"`    // retry over HTTPS on TCP when QUIC fails
+			} `yaml:"http3_burst"`
 			HTTP_Rate struct {
-				Enable   bool  `yaml:"enable"`
-				Duration uint  `yaml:"duration"`
-				Rates    []int `yaml:"rates"`
+				Enable           bool              `yaml:"enable"`
+				Repetitions      uint              `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration         uint              `yaml:"duration"`
+				Rates            []int             `yaml:"rates"`
+				Protocol         string            `yaml:"protocol"`    // http1, http1-close or h2, Go's defaults if empty
+				MaxStreams       uint              `yaml:"max_streams"` // concurrent HTTP/2 streams
+				ConnectionPolicy ConnectionPolicy  `yaml:"connection_policy"`
+				ComparePolicy    *ConnectionPolicy `yaml:"compare_policy"` // if set, every step is also run under this policy and the deltas are reported
 			} `yaml:"http_rate"`
 			HTTPS_Rate struct {
-				Enable   bool  `yaml:"enable"`
-				Duration uint  `yaml:"duration"`
-				Rates    []int `yaml:"rates"`
+				Enable           bool              `yaml:"enable"`
+				Repetitions      uint              `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration         uint              `yaml:"duration"`
+				Rates            []int             `yaml:"rates"`
+				Protocol         string            `yaml:"protocol"`    // http1, http1-close or h2, Go's defaults if empty
+				MaxStreams       uint              `yaml:"max_streams"` // concurrent HTTP/2 streams
+				ConnectionPolicy ConnectionPolicy  `yaml:"connection_policy"`
+				ComparePolicy    *ConnectionPolicy `yaml:"compare_policy"` // if set, every step is also run under this policy and the deltas are reported
 			} `yaml:"https_rate"`
+			HTTP3_Rate struct {
+				Enable      bool  `yaml:"enable"`
+				Repetitions uint  `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint  `yaml:"duration"`
+				Rates       []int `yaml:"rates"`
+				Fallback    bool  `yaml:"fallback"` // retry over HTTPS on TCP when QUIC fails
+			} `yaml:"http3_rate"`
+			GRPC struct {
+				Enable        bool   `yaml:"enable"`
+				Secure        bool   `yaml:"secure"` // use TLS, trusting ca.crt
+				Duration      uint   `yaml:"duration"`
+				Rates         []int  `yaml:"rates"`          // unary calls per second to test
+				MessageSize   uint   `yaml:"message_size"`   // bytes per unary call and bidi message
+				StreamBytes   uint64 `yaml:"stream_bytes"`   // bytes requested from the server-streaming call
+				CountMessages uint   `yaml:"count_messages"` // bidi messages timed for RTT
+				Repetitions   uint   `yaml:"repetitions"`    // runs of each step, 1 if 0
+			} `yaml:"grpc"`
+			WS_Echo struct {
+				Enable        bool   `yaml:"enable"`
+				MessageSizes  []uint `yaml:"message_sizes"`  // bytes
+				CountMessages uint   `yaml:"count_messages"` // sequential messages timed for RTT
+				Duration      uint   `yaml:"duration"`       // seconds of the message rate phase
+				Repetitions   uint   `yaml:"repetitions"`    // runs of each step, 1 if 0
+			} `yaml:"ws_echo"`
+			WSS_Echo struct {
+				Enable        bool   `yaml:"enable"`
+				MessageSizes  []uint `yaml:"message_sizes"`  // bytes
+				CountMessages uint   `yaml:"count_messages"` // sequential messages timed for RTT
+				Duration      uint   `yaml:"duration"`       // seconds of the message rate phase
+				Repetitions   uint   `yaml:"repetitions"`    // runs of each step, 1 if 0
+			} `yaml:"wss_echo"`
 			DNS_UDP_Burst struct {
-				Enable   bool `yaml:"enable"`
-				Duration uint `yaml:"duration"`
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint `yaml:"duration"`
 			} `yaml:"dns_udp_burst"`
 			DNS_TCP_Burst struct {
-				Enable   bool `yaml:"enable"`
-				Duration uint `yaml:"duration"`
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint `yaml:"duration"`
 			} `yaml:"dns_tcp_burst"`
 			DNS_UDP_Rate struct {
-				Enable   bool  `yaml:"enable"`
-				Duration uint  `yaml:"duration"`
-				Rates    []int `yaml:"rates"`
+				Enable      bool  `yaml:"enable"`
+				Repetitions uint  `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint  `yaml:"duration"`
+				Rates       []int `yaml:"rates"`
 			} `yaml:"dns_udp_rate"`
 			DNS_TCP_Rate struct {
-				Enable   bool  `yaml:"enable"`
-				Duration uint  `yaml:"duration"`
-				Rates    []int `yaml:"rates"`
+				Enable      bool  `yaml:"enable"`
+				Repetitions uint  `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint  `yaml:"duration"`
+				Rates       []int `yaml:"rates"`
 			} `yaml:"dns_tcp_rate"`
+			DNS_DoT_Burst struct {
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
+			} `yaml:"dns_dot_burst"`
+			DNS_DoT_Rate struct {
+				Enable      bool  `yaml:"enable"`
+				Repetitions uint  `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint  `yaml:"duration"`
+				Rates       []int `yaml:"rates"`
+			} `yaml:"dns_dot_rate"`
+			DNS_DoH_Burst struct {
+				Enable      bool   `yaml:"enable"`
+				Repetitions uint   `yaml:"repetitions"` // runs of each step, 1 if 0
+				Method      string `yaml:"method"`      // GET or POST
+			} `yaml:"dns_doh_burst"`
+			DNS_DoH_Rate struct {
+				Enable      bool   `yaml:"enable"`
+				Repetitions uint   `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint   `yaml:"duration"`
+				Rates       []int  `yaml:"rates"`
+				Method      string `yaml:"method"` // GET or POST
+			} `yaml:"dns_doh_rate"`
+			DNS_DoQ_Burst struct {
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
+			} `yaml:"dns_doq_burst"`
+			DNS_DoQ_Rate struct {
+				Enable      bool  `yaml:"enable"`
+				Repetitions uint  `yaml:"repetitions"` // runs of each step, 1 if 0
+				Duration    uint  `yaml:"duration"`
+				Rates       []int `yaml:"rates"`
+			} `yaml:"dns_doq_rate"`
+			DNS_Matrix struct {
+				Enable          bool     `yaml:"enable"`
+				Repetitions     uint     `yaml:"repetitions"` // runs of each combination, 1 if 0
+				Transport       string   `yaml:"transport"`   // udp, tcp, tcp-tls, https-get, https-post or quic
+				CountQueries    uint     `yaml:"count_queries"`
+				QueryTypes      []string `yaml:"query_types"`
+				ResponseSizes   []uint   `yaml:"response_sizes"`
+				EDNS_BufferSize uint16   `yaml:"edns_buffer_size"`
+			} `yaml:"dns_matrix"`
 			HTTP_Throughput struct {
-				Enable bool `yaml:"enable"`
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
 			} `yaml:"http_throughput"`
 			HTTPS_Throughput struct {
-				Enable bool `yaml:"enable"`
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
 			} `yaml:"https_throughput"`
+			HTTP3_Throughput struct {
+				Enable      bool `yaml:"enable"`
+				Repetitions uint `yaml:"repetitions"` // runs of each step, 1 if 0
+				Fallback    bool `yaml:"fallback"`    // retry over HTTPS on TCP when QUIC fails
+			} `yaml:"http3_throughput"`
 			Ping struct {
 				Enable       bool `yaml:"enable"`
 				CountSamples uint `yaml:"countSamples"`
+				Repetitions  uint `yaml:"repetitions"` // runs of the test, 1 if 0
 			} `yaml:"ping"`
+			ICMP_Ping struct {
+				Enable       bool `yaml:"enable"`
+				CountSamples uint `yaml:"countSamples"`
+				Repetitions  uint `yaml:"repetitions"` // runs of the test, 1 if 0
+			} `yaml:"icmp_ping"`
+			TCP_Ping struct {
+				Enable       bool   `yaml:"enable"`
+				CountSamples uint   `yaml:"countSamples"`
+				Repetitions  uint   `yaml:"repetitions"` // runs of each probe, 1 if 0
+				Ports        []uint `yaml:"ports"`       // ports to time TCP connections to
+			} `yaml:"tcp_ping"`
 			Jitter struct {
 				Enable           bool `yaml:"enable"`
 				CountDifferences uint `yaml:"countDifferences"`
+				Repetitions      uint `yaml:"repetitions"` // runs of the test, 1 if 0
 			} `yaml:"jitter"`
 		} `yaml:"tests"`
 	} `yaml:"client"`
 }
 
+// ConnectionPolicy controls how the HTTP tests open and reuse connections
+type ConnectionPolicy struct {
+	DisableKeepAlives   bool `yaml:"disable_keep_alives"`     // use a new connection for every request
+	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"` // idle connections kept for reuse, Go's default of 2 if 0
+	Prewarm             uint `yaml:"prewarm"`                 // connections opened before each step
+}
+
+// LoggingConfig configures the log of a run, written to the console and to the run's -log.log file
+type LoggingConfig struct {
+	Level       string `yaml:"level"`        // debug, info, warn or error, info if empty
+	Format      string `yaml:"format"`       // text or json, text if empty
+	RepeatLimit uint   `yaml:"repeat_limit"` // errors or warnings of the same message logged per 10 s before the rest are summarized, 10 if 0
+}
+
+// ProxyConfig selects the proxy of the tests that support one
+type ProxyConfig struct {
+	URL         string `yaml:"url"` // http://, https:// or socks5:// proxy, none if empty
+	PAC         string `yaml:"pac"` // URL or file path of a proxy auto-config file, used instead of url
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	Direct      bool   `yaml:"direct"`       // connect directly, ignoring url, pac and the environment
+	DNS_OverTCP bool   `yaml:"dns_over_tcp"` // also send DNS over TCP queries through the proxy
+	Environment bool   `yaml:"environment"`  // use HTTP_PROXY, HTTPS_PROXY and NO_PROXY if url and pac are empty, rather than connecting directly
+}
+
+// ComparisonPath is one of the two network paths or configs compared in comparison mode; unset fields keep the client's value
+type ComparisonPath struct {
+	Name         string       `yaml:"name"`
+	ServerHost   string       `yaml:"server_host"`
+	LocalAddress string       `yaml:"local_address"`
+	Proxy        *ProxyConfig `yaml:"proxy"`
+}
+
+// CompareThresholds configures when the compare command reports a change as a regression
+type CompareThresholds struct {
+	Default float64                       `yaml:"default"` // change in percent in the worse direction, 10 if 0
+	Alpha   float64                       `yaml:"alpha"`   // significance level of the t-test when there are several runs per side, 0.05 if 0
+	Metrics map[string]float64            `yaml:"metrics"` // thresholds by column name, e.g. "p99 latency (ms)"
+	Tests   map[string]map[string]float64 `yaml:"tests"`   // thresholds by test name, e.g. "-httpsBurstTest", and then column name
+}
+
 type ProgramArgs struct {
 	ConfigFile string
 }
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// LocalDialer creates a dialer for network ("tcp" or "udp") whose connections are bound to localAddress, the IP address
+// of the interface to test through, or to any address if localAddress is empty
+func LocalDialer(network string, localAddress string, timeout time.Duration) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	ip := net.ParseIP(localAddress)
+	if ip == nil {
+		return dialer
+	}
+	if network == "udp" {
+		dialer.LocalAddr = &net.UDPAddr{IP: ip}
+	} else {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return dialer
+}
+
+// QUICDialer dials QUIC connections from a UDP socket bound to a local address. The socket is opened on the first dial
+// and shared by every connection until Close.
+type QUICDialer struct {
+	localAddress string
+	transport    *quic.Transport
+	mutex        sync.Mutex
+}
+
+// NewQUICDialer creates a dialer whose connections are bound to localAddress, or to any address if it is empty
+func NewQUICDialer(localAddress string) *QUICDialer {
+	return &QUICDialer{localAddress: localAddress}
+}
+
+// Dial dials a QUIC connection to address, a host and port
+func (e *QUICDialer) Dial(ctx context.Context, address string, tlsConfig *tls.Config, config *quic.Config) (*quic.Conn, error) {
+	transport, udpAddr, err := e.prepare(address)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Dial(ctx, udpAddr, tlsConfig, config)
+}
+
+// DialEarly dials a QUIC connection to address that can send 0-RTT data, as http3.Transport.Dial expects
+func (e *QUICDialer) DialEarly(ctx context.Context, address string, tlsConfig *tls.Config, config *quic.Config) (*quic.Conn, error) {
+	transport, udpAddr, err := e.prepare(address)
+	if err != nil {
+		return nil, err
+	}
+	return transport.DialEarly(ctx, udpAddr, tlsConfig, config)
+}
+
+// Close closes the connections of the dialer and its socket; a later dial opens a new one
+func (e *QUICDialer) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.transport == nil {
+		return nil
+	}
+	e.transport.Close()
+	err := e.transport.Conn.Close()
+	e.transport = nil
+	return err
+}
+
+// prepare opens the socket of the dialer if it is not open yet and resolves address
+func (e *QUICDialer) prepare(address string) (*quic.Transport, *net.UDPAddr, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.transport == nil {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(e.localAddress)})
+		if err != nil {
+			return nil, nil, err
+		}
+		e.transport = &quic.Transport{Conn: conn}
+	}
+	return e.transport, udpAddr, nil
+}
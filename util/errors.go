@@ -0,0 +1,58 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ClassifyError reduces a network error to a short failure class so failures can be counted by cause
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "resolve"
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "certificate"), strings.Contains(message, "tls:"):
+		return "tls"
+	case strings.Contains(message, "connection refused"):
+		return "refused"
+	case strings.Contains(message, "connection reset"):
+		return "reset"
+	}
+	return "other"
+}
+
+// FormatCounts formats counts such as failure classes as "key=count" pairs for a single CSV cell
+func FormatCounts(counts map[string]uint) string {
+	var keys []string
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+strconv.FormatUint(uint64(counts[key]), 10))
+	}
+	return strings.Join(pairs, ";")
+}
@@ -3,31 +3,46 @@ package util
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 )
 
-// CreateHTTPSClient creates an HTTP client that trusts our custom CA
-func CreateHTTPSClient() *http.Client {
+// CreateTLSConfig creates a TLS config that trusts our custom CA, or the system roots if it cannot be loaded
+func CreateTLSConfig() *tls.Config {
 	// Load CA certificate
 	caCert, err := ioutil.ReadFile("ca.crt")
 	if err != nil {
-		// If CA cert not found, return regular client (for HTTP tests)
-		return &http.Client{}
+		return &tls.Config{}
 	}
 
 	// Create CA certificate pool
 	caCertPool := x509.NewCertPool()
 	if !caCertPool.AppendCertsFromPEM(caCert) {
-		// If CA cert parsing fails, return regular client
-		return &http.Client{}
+		return &tls.Config{}
 	}
 
 	// Create TLS config with our CA
-	tlsConfig := &tls.Config{
+	return &tls.Config{
 		RootCAs:            caCertPool,
 		InsecureSkipVerify: false,
 	}
+}
+
+// CreateHTTPSClient creates an HTTP client that trusts our custom CA
+func CreateHTTPSClient() *http.Client {
+	tlsConfig := CreateTLSConfig()
+	if tlsConfig.RootCAs == nil {
+		// If CA cert not found or could not be parsed, return regular client (for HTTP tests)
+		return &http.Client{}
+	}
 
 	// Create HTTP client with custom TLS config
 	return &http.Client{
@@ -36,3 +51,171 @@ func CreateHTTPSClient() *http.Client {
 		},
 	}
 }
+
+// HTTPClientOptions selects the protocol and connection reuse policy of the clients created by CreateHTTPClient
+type HTTPClientOptions struct {
+	// "" for Go's defaults, "http1" for HTTP/1.1 with keep-alive, "http1-close" for HTTP/1.1 with a new connection per request,
+	// "h2" for HTTP/2 multiplexed over a single connection (h2c for plain HTTP) or "h3" for HTTP/3 over QUIC
+	Protocol            string
+	MaxStreams          uint           // the maximum number of concurrent HTTP/2 streams, 100 if 0
+	HTTP3Fallback       bool           // retry HTTP/3 requests over HTTPS on TCP when QUIC fails
+	DisableKeepAlives   bool           // use a new connection for every request
+	MaxIdleConnsPerHost int            // idle connections kept for reuse, Go's default of 2 if 0
+	PrewarmConnections  uint           // connections opened before a test starts
+	Proxy               *ProxySelector // the proxy for requests over TCP, none if nil; HTTP/3 is never proxied
+	LocalAddress        string         // the IP address to connect from, any if empty
+}
+
+const defaultMaxStreams = 100
+
+// CreateHTTPClient creates an HTTP client that trusts our custom CA and uses the protocol and connection reuse policy selected by options
+func CreateHTTPClient(options HTTPClientOptions) *http.Client {
+	if options.Protocol == "h3" {
+		return &http.Client{Transport: NewHTTP3Transport(options.HTTP3Fallback, options.LocalAddress)}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig := CreateTLSConfig(); tlsConfig.RootCAs != nil {
+		// as in CreateHTTPSClient, a custom TLS config means HTTP/1.1 unless HTTP/2 is selected
+		transport.TLSClientConfig = tlsConfig
+		transport.ForceAttemptHTTP2 = false
+	}
+	paths := &proxyPaths{paths: map[string]uint{}}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := options.Proxy.ProxyForRequest(req)
+		if err == nil {
+			paths.add(ProxyPath(proxyURL))
+		}
+		return proxyURL, err
+	}
+	if options.LocalAddress != "" {
+		transport.DialContext = LocalDialer("tcp", options.LocalAddress, 30*time.Second).DialContext
+	}
+	transport.DisableKeepAlives = options.DisableKeepAlives
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < options.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = options.MaxIdleConnsPerHost
+		}
+	}
+
+	switch options.Protocol {
+	case "http1", "http1-close":
+		if options.Protocol == "http1-close" {
+			transport.DisableKeepAlives = true
+		}
+		transport.Protocols = &http.Protocols{}
+		transport.Protocols.SetHTTP1(true)
+	case "h2":
+		maxStreams := options.MaxStreams
+		if maxStreams == 0 {
+			maxStreams = defaultMaxStreams
+		}
+		// a single connection carries every request, with at most maxStreams of them in flight
+		transport.MaxConnsPerHost = 1
+		transport.Protocols = &http.Protocols{}
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+		return &http.Client{Transport: &proxyTransport{&streamLimitTransport{transport: transport, streams: make(chan struct{}, maxStreams)}, paths}}
+	}
+	return &http.Client{Transport: &proxyTransport{transport, paths}}
+}
+
+// streamLimitTransport limits the number of requests in flight, so that an HTTP/2 connection never needs more than that many streams
+type streamLimitTransport struct {
+	transport http.RoundTripper
+	streams   chan struct{}
+}
+
+func (e *streamLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e.streams <- struct{}{}
+	resp, err := e.transport.RoundTrip(req)
+	if err != nil {
+		<-e.streams
+		return nil, err
+	}
+	// the stream stays open until the response body has been read and closed
+	resp.Body = &streamBody{ReadCloser: resp.Body, release: func() { <-e.streams }}
+	return resp, nil
+}
+
+// streamBody releases its stream the first time it is closed
+type streamBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (e *streamBody) Close() error {
+	err := e.ReadCloser.Close()
+	e.once.Do(e.release)
+	return err
+}
+
+// HTTP3Transport makes requests over HTTP/3 and, if fallback is enabled, retries them over HTTPS on TCP when QUIC fails.
+// Like a browser, once QUIC has failed it is not tried again for the lifetime of the transport.
+type HTTP3Transport struct {
+	h3             *http3.Transport
+	tcp            *http.Transport
+	fallback       bool
+	broken         int32
+	countFallbacks int64
+	fallbackCost   int64 // nanoseconds spent on failed QUIC attempts
+}
+
+// NewHTTP3Transport creates an HTTP/3 transport that trusts our custom CA and connects from localAddress, or from any
+// address if it is empty
+func NewHTTP3Transport(fallback bool, localAddress string) *HTTP3Transport {
+	transport := &HTTP3Transport{
+		h3: &http3.Transport{
+			TLSClientConfig: CreateTLSConfig(),
+			QUICConfig:      &quic.Config{HandshakeIdleTimeout: 3 * time.Second},
+		},
+		tcp:      &http.Transport{TLSClientConfig: CreateTLSConfig(), ForceAttemptHTTP2: true},
+		fallback: fallback,
+	}
+	if localAddress != "" {
+		transport.h3.Dial = NewQUICDialer(localAddress).DialEarly
+		transport.tcp.DialContext = LocalDialer("tcp", localAddress, 30*time.Second).DialContext
+	}
+	return transport
+}
+
+func (e *HTTP3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.LoadInt32(&e.broken) == 0 {
+		tStart := time.Now()
+		resp, err := e.h3.RoundTrip(req)
+		if err == nil || !e.fallback {
+			return resp, err
+		}
+		// a streamed request body has been consumed and cannot be sent again
+		if req.Body != nil && req.GetBody == nil {
+			return nil, err
+		}
+		atomic.StoreInt32(&e.broken, 1)
+		atomic.AddInt64(&e.fallbackCost, int64(time.Since(tStart)))
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+	atomic.AddInt64(&e.countFallbacks, 1)
+	return e.tcp.RoundTrip(req)
+}
+
+// Fallbacks returns the number of requests sent over TCP instead of QUIC and the time lost on failed QUIC attempts
+func (e *HTTP3Transport) Fallbacks() (uint, time.Duration) {
+	return uint(atomic.LoadInt64(&e.countFallbacks)), time.Duration(atomic.LoadInt64(&e.fallbackCost))
+}
+
+// HTTPClientFallbacks returns the HTTP/3 fallbacks of a client created by CreateHTTPClient, or zero for other protocols
+func HTTPClientFallbacks(client *http.Client) (uint, time.Duration) {
+	if transport, ok := client.Transport.(*HTTP3Transport); ok {
+		return transport.Fallbacks()
+	}
+	return 0, 0
+}
@@ -0,0 +1,281 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+	"golang.org/x/term"
+)
+
+const (
+	defaultRepeatLimit = 10               // errors and warnings of a message logged per repeatInterval before the rest are summarized
+	repeatInterval     = 10 * time.Second // interval over which repeated errors and warnings are counted
+)
+
+// SetupLogging makes the default slog logger, and the log package, log to the console and also to file if it is not
+// nil. Errors and warnings repeated more often than the configured limit are summarized. The returned function logs
+// the summaries still pending and makes the logger log to the console only, e.g. before file is closed
+func SetupLogging(config types.LoggingConfig, file io.Writer) (func(), error) {
+	var level slog.Level
+	if config.Level != "" {
+		if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", config.Level)
+		}
+	}
+	var console, logFile slog.Handler
+	options := &slog.HandlerOptions{Level: level}
+	switch config.Format {
+	case "", "text":
+		console = &consoleHandler{level: level, color: term.IsTerminal(int(os.Stdout.Fd())), mutex: &sync.Mutex{}}
+		if file != nil {
+			logFile = slog.NewTextHandler(file, options)
+		}
+	case "json":
+		console = slog.NewJSONHandler(stdout{}, options)
+		if file != nil {
+			logFile = slog.NewJSONHandler(file, options)
+		}
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected text or json", config.Format)
+	}
+	limit := int(config.RepeatLimit)
+	if limit == 0 {
+		limit = defaultRepeatLimit
+	}
+
+	handlers := []slog.Handler{console}
+	if logFile != nil {
+		handlers = append(handlers, logFile)
+	}
+	limiter := &repeatLimiter{limit: limit, counts: map[string]*repeatCount{}, handler: multiHandler(handlers)}
+	slog.SetDefault(slog.New(repeatLimitHandler{next: limiter.handler, limiter: limiter}))
+	return func() {
+		limiter.flush()
+		limiter = &repeatLimiter{limit: limit, counts: map[string]*repeatCount{}, handler: console}
+		slog.SetDefault(slog.New(repeatLimitHandler{next: console, limiter: limiter}))
+	}, nil
+}
+
+// stdout writes to the standard output at the time of writing, which the dashboard replaces while it is shown
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// consoleHandler logs a record as its message followed by its attributes as key=value, colored by level on a terminal
+type consoleHandler struct {
+	level slog.Leveler
+	color bool
+	attrs string // preformatted attributes of WithAttrs
+	group string // prefix of the keys of attributes, of WithGroup
+	mutex *sync.Mutex
+}
+
+func (e *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= e.level.Level()
+}
+
+func (e *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var line strings.Builder
+	line.WriteString(r.Message)
+	line.WriteString(e.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&line, e.group, a)
+		return true
+	})
+	text := line.String()
+	if e.color {
+		switch {
+		case r.Level >= slog.LevelError:
+			text = "\033[1;31m" + text + "\033[0m"
+		case r.Level >= slog.LevelWarn:
+			text = "\033[1;33m" + text + "\033[0m"
+		case r.Level < slog.LevelInfo:
+			text = "\033[0;36m" + text + "\033[0m"
+		}
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	_, err := io.WriteString(os.Stdout, text+"\n")
+	return err
+}
+
+func (e *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var line strings.Builder
+	for _, a := range attrs {
+		appendAttr(&line, e.group, a)
+	}
+	handler := *e
+	handler.attrs += line.String()
+	return &handler
+}
+
+func (e *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return e
+	}
+	handler := *e
+	handler.group += name + "."
+	return &handler
+}
+
+// appendAttr appends " key=value" to line, quoting the value if needed and flattening groups
+func appendAttr(line *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			appendAttr(line, group, member)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(line, " %s%s=%s", group, a.Key, value)
+}
+
+// multiHandler passes every record to each of its handlers that is enabled for it
+type multiHandler []slog.Handler
+
+func (e multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range e {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range e {
+		if handler.Enabled(ctx, r.Level) {
+			if err := handler.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (e multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(e))
+	for i, handler := range e {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (e multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(e))
+	for i, handler := range e {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+// repeatLimitHandler drops the errors and warnings of a message once it was logged limit times in a repeatInterval, and
+// logs how many were dropped at the end of the interval instead
+type repeatLimitHandler struct {
+	next    slog.Handler
+	limiter *repeatLimiter
+}
+
+func (e repeatLimitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return e.next.Enabled(ctx, level)
+}
+
+func (e repeatLimitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn && !e.limiter.allow(r.Level, r.Message) {
+		return nil
+	}
+	return e.next.Handle(ctx, r)
+}
+
+func (e repeatLimitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return repeatLimitHandler{next: e.next.WithAttrs(attrs), limiter: e.limiter}
+}
+
+func (e repeatLimitHandler) WithGroup(name string) slog.Handler {
+	return repeatLimitHandler{next: e.next.WithGroup(name), limiter: e.limiter}
+}
+
+// repeatLimiter counts the errors and warnings of every message
+type repeatLimiter struct {
+	mutex   sync.Mutex
+	limit   int
+	counts  map[string]*repeatCount // by level and message
+	timer   *time.Timer             // flushes the counts, running while any record is dropped
+	handler slog.Handler            // logs the summaries
+}
+
+type repeatCount struct {
+	level      slog.Level
+	message    string
+	started    time.Time
+	countDrops int
+	countLogs  int
+}
+
+// allow returns whether a record of level and message is logged, counting it
+func (e *repeatLimiter) allow(level slog.Level, message string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	now := time.Now()
+	key := level.String() + " " + message
+	count, ok := e.counts[key]
+	if !ok || count.countDrops == 0 && now.Sub(count.started) >= repeatInterval {
+		count = &repeatCount{level: level, message: message, started: now}
+		e.counts[key] = count
+	}
+	if count.countLogs < e.limit {
+		count.countLogs++
+		return true
+	}
+	count.countDrops++
+	if e.timer == nil {
+		e.timer = time.AfterFunc(repeatInterval, e.flush)
+	}
+	return false
+}
+
+// flush logs how many records of every message were dropped and starts counting anew
+func (e *repeatLimiter) flush() {
+	e.mutex.Lock()
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	var summaries []slog.Record
+	for key, count := range e.counts {
+		if count.countDrops > 0 {
+			r := slog.NewRecord(time.Now(), count.level, count.message, 0)
+			r.AddAttrs(slog.Int("repeated", count.countDrops), slog.Duration("within", time.Since(count.started).Round(time.Second)))
+			summaries = append(summaries, r)
+			delete(e.counts, key)
+		}
+	}
+	e.mutex.Unlock()
+	for _, r := range summaries {
+		e.handler.Handle(context.Background(), r)
+	}
+}
@@ -0,0 +1,139 @@
+package util
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+)
+
+func TestRepeatLimitHandler(t *testing.T) {
+	var output bytes.Buffer
+	handler := slog.NewTextHandler(&output, nil)
+	limiter := &repeatLimiter{limit: 3, counts: map[string]*repeatCount{}, handler: handler}
+	logger := slog.New(repeatLimitHandler{next: handler, limiter: limiter})
+	for i := 0; i < 5; i++ {
+		logger.Error("DNS query failed", "i", i)
+		logger.Warn("Slow response", "i", i)
+		logger.Info("Query sent", "i", i)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if count := countLines(lines, "DNS query failed"); count != 3 {
+		t.Errorf("logged %d of 5 repeated errors, want the limit of 3:\n%s", count, output.String())
+	}
+	if count := countLines(lines, "Slow response"); count != 3 {
+		t.Errorf("logged %d of 5 repeated warnings, want the limit of 3:\n%s", count, output.String())
+	}
+	if count := countLines(lines, "Query sent"); count != 5 {
+		t.Errorf("logged %d of 5 infos, want all of them:\n%s", count, output.String())
+	}
+	if countLines(lines, "level=ERROR msg=\"DNS query failed\" i=3") > 0 || countLines(lines, "level=ERROR msg=\"DNS query failed\" i=4") > 0 {
+		t.Errorf("an error after the limit was logged:\n%s", output.String())
+	}
+
+	output.Reset()
+	limiter.flush()
+	lines = strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 || countLines(lines, "DNS query failed") != 1 || countLines(lines, "Slow response") != 1 || countLines(lines, "repeated=2") != 2 {
+		t.Errorf("flush logged %q, want one summary of each message with repeated=2", lines)
+	}
+
+	// the flush started counting anew
+	output.Reset()
+	logger.Error("DNS query failed")
+	if !strings.Contains(output.String(), "DNS query failed") {
+		t.Errorf("an error after the flush was dropped")
+	}
+	limiter.flush()
+}
+
+func TestRepeatLimiterInterval(t *testing.T) {
+	limiter := &repeatLimiter{limit: 2, counts: map[string]*repeatCount{}, handler: slog.NewTextHandler(&bytes.Buffer{}, nil)}
+	defer limiter.flush()
+	for i := 0; i < 2; i++ {
+		if !limiter.allow(slog.LevelError, "DNS query failed") {
+			t.Fatalf("error %d within the limit was dropped", i+1)
+		}
+	}
+	// the limit applies again once the interval has passed
+	limiter.counts["ERROR DNS query failed"].started = time.Now().Add(-repeatInterval)
+	for i := 0; i < 2; i++ {
+		if !limiter.allow(slog.LevelError, "DNS query failed") {
+			t.Fatalf("error %d of the next interval was dropped", i+1)
+		}
+	}
+	if limiter.allow(slog.LevelError, "DNS query failed") {
+		t.Errorf("error after the limit of the next interval was logged")
+	}
+	if limiter.allow(slog.LevelError, "DNS query failed") || limiter.counts["ERROR DNS query failed"].countDrops != 2 {
+		t.Errorf("dropped %d errors, want 2", limiter.counts["ERROR DNS query failed"].countDrops)
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	for _, c := range []struct {
+		format string
+		want   []string
+	}{
+		{"text", []string{"Disk almost full", "free_mb=12"}},
+		{"json", []string{`"msg":"Disk almost full"`, `"free_mb":12`, `"level":"WARN"`}},
+	} {
+		console, err := os.CreateTemp(t.TempDir(), "console")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = console
+		var file bytes.Buffer
+		stop, err := SetupLogging(types.LoggingConfig{Format: c.format}, &file)
+		if err != nil {
+			os.Stdout = stdout
+			t.Fatalf("%s: SetupLogging failed: %v", c.format, err)
+		}
+		slog.Warn("Disk almost full", "free_mb", 12)
+		stop()
+		slog.Info("Logging stopped")
+		os.Stdout = stdout
+		console.Close()
+
+		consoleOutput, err := os.ReadFile(console.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range c.want {
+			if !strings.Contains(string(consoleOutput), want) {
+				t.Errorf("%s: the console does not show %q:\n%s", c.format, want, consoleOutput)
+			}
+			if !strings.Contains(file.String(), want) {
+				t.Errorf("%s: the file does not have %q:\n%s", c.format, want, file.String())
+			}
+		}
+		// once stopped, the log is written to the console only
+		if !strings.Contains(string(consoleOutput), "Logging stopped") || strings.Contains(file.String(), "Logging stopped") {
+			t.Errorf("%s: a record logged after stopping is not on the console only:\n%s\n%s", c.format, consoleOutput, file.String())
+		}
+	}
+
+	if _, err := SetupLogging(types.LoggingConfig{Format: "xml"}, nil); err == nil {
+		t.Errorf("SetupLogging accepted the format xml")
+	}
+	if _, err := SetupLogging(types.LoggingConfig{Level: "loud"}, nil); err == nil {
+		t.Errorf("SetupLogging accepted the level loud")
+	}
+}
+
+// countLines returns the number of lines containing substring
+func countLines(lines []string, substring string) int {
+	count := 0
+	for _, line := range lines {
+		if strings.Contains(line, substring) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// pacFunctions implements the PAC helper functions in JavaScript on top of dnsResolve and myIpAddress, which are provided in Go
+const pacFunctions = `
+function isPlainHostName(host) { return host.indexOf('.') < 0; }
+function dnsDomainIs(host, domain) { return host.length >= domain.length && host.substring(host.length - domain.length) === domain; }
+function localHostOrDomainIs(host, hostdom) { return host === hostdom || hostdom.lastIndexOf(host + '.', 0) === 0; }
+function isResolvable(host) { return dnsResolve(host) !== null; }
+function dnsDomainLevels(host) { return host.split('.').length - 1; }
+function convertAddr(ip) { var b = ip.split('.'); return ((b[0] << 24) | (b[1] << 16) | (b[2] << 8) | b[3]) >>> 0; }
+function isInNet(host, pattern, mask) {
+	var ip = /^\d+\.\d+\.\d+\.\d+$/.test(host) ? host : dnsResolve(host);
+	if (ip === null) { return false; }
+	var m = convertAddr(mask);
+	return (convertAddr(ip) & m) === (convertAddr(pattern) & m);
+}
+function shExpMatch(str, shexp) {
+	var re = shexp.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.');
+	return new RegExp('^' + re + '$').test(str);
+}
+function weekdayRange() { return true; }
+function dateRange() { return true; }
+function timeRange() { return true; }
+`
+
+// pacScript evaluates FindProxyForURL of a proxy auto-config file
+type pacScript struct {
+	mutex sync.Mutex // a goja runtime must not be used concurrently
+	vm    *goja.Runtime
+	find  goja.Callable
+}
+
+// loadPAC loads the PAC file at location, which is an http(s) URL or a file path
+func loadPAC(location string) (*pacScript, error) {
+	var source []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		var resp *http.Response
+		resp, err = CreateHTTPSClient().Get(location)
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("could not load PAC file %s: %s", location, resp.Status)
+			}
+			source, err = ioutil.ReadAll(resp.Body)
+		}
+	} else {
+		source, err = ioutil.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load PAC file %s: %v", location, err)
+	}
+
+	vm := goja.New()
+	vm.Set("dnsResolve", func(host string) interface{} {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				return ip.String()
+			}
+		}
+		return nil
+	})
+	vm.Set("myIpAddress", func() string {
+		// the address of the interface used to reach the internet, without sending anything
+		conn, err := net.Dial("udp", "198.51.100.1:53")
+		if err != nil {
+			return "127.0.0.1"
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP.String()
+	})
+	if _, err := vm.RunString(pacFunctions); err != nil {
+		return nil, err
+	}
+	if _, err := vm.RunString(string(source)); err != nil {
+		return nil, fmt.Errorf("could not evaluate PAC file %s: %v", location, err)
+	}
+	find, ok := goja.AssertFunction(vm.Get("FindProxyForURL"))
+	if !ok {
+		return nil, fmt.Errorf("PAC file %s does not define FindProxyForURL", location)
+	}
+	return &pacScript{vm: vm, find: find}, nil
+}
+
+// FindProxy returns the proxy to use for rawURL, or nil to connect directly
+func (e *pacScript) FindProxy(rawURL string, host string) (*url.URL, error) {
+	e.mutex.Lock()
+	result, err := e.find(goja.Undefined(), e.vm.ToValue(rawURL), e.vm.ToValue(host))
+	e.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return parsePACResult(result.String())
+}
+
+// parsePACResult returns the first usable proxy of a FindProxyForURL result such as "PROXY proxy:3128; DIRECT", or nil for DIRECT
+func parsePACResult(result string) (*url.URL, error) {
+	if strings.TrimSpace(result) == "" {
+		return nil, nil
+	}
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		var scheme string
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			continue // e.g. SOCKS4, which is not supported
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		return &url.URL{Scheme: scheme, Host: fields[1]}, nil
+	}
+	return nil, fmt.Errorf("no supported proxy in PAC result %q", result)
+}
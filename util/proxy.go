@@ -0,0 +1,218 @@
+package util
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyOptions configures the proxy used by the HTTP tests
+type ProxyOptions struct {
+	URL      string // "http://host:port", "https://host:port" or "socks5://host:port"
+	PAC      string // URL or file path of a proxy auto-config file, used instead of URL
+	Username string // proxy credentials, if not already in URL
+	Password string
+	Direct   bool // connect directly, ignoring URL, PAC and the environment
+	// use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables if neither URL nor PAC is set, rather than
+	// connecting directly
+	Environment bool
+}
+
+// ProxySelector chooses the proxy for each connection from a fixed URL, a PAC file or, if neither is configured and
+// Environment is set, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. A nil ProxySelector connects
+// directly.
+type ProxySelector struct {
+	proxyURL    *url.URL
+	pac         *pacScript
+	user        *url.Userinfo
+	direct      bool
+	environment bool
+}
+
+// NewProxySelector creates a ProxySelector, loading the PAC file if one is configured
+func NewProxySelector(options ProxyOptions) (*ProxySelector, error) {
+	selector := &ProxySelector{direct: options.Direct, environment: options.Environment}
+	if options.Direct {
+		return selector, nil
+	}
+	if options.Username != "" {
+		selector.user = url.UserPassword(options.Username, options.Password)
+	}
+	if options.PAC != "" {
+		pac, err := loadPAC(options.PAC)
+		if err != nil {
+			return nil, err
+		}
+		selector.pac = pac
+		return selector, nil
+	}
+	if options.URL != "" {
+		proxyURL, err := url.Parse(options.URL)
+		if err != nil {
+			return nil, err
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+		selector.proxyURL = selector.withUser(proxyURL)
+	}
+	return selector, nil
+}
+
+func (e *ProxySelector) withUser(proxyURL *url.URL) *url.URL {
+	if proxyURL == nil || proxyURL.User != nil || e.user == nil {
+		return proxyURL
+	}
+	withUser := *proxyURL
+	withUser.User = e.user
+	return &withUser
+}
+
+// ProxyForRequest returns the proxy for req, or nil to connect directly. It can be used as http.Transport.Proxy.
+func (e *ProxySelector) ProxyForRequest(req *http.Request) (*url.URL, error) {
+	if e == nil || e.direct {
+		return nil, nil
+	}
+	if e.pac == nil && e.proxyURL == nil {
+		if e.environment {
+			return http.ProxyFromEnvironment(req)
+		}
+		return nil, nil
+	}
+	if e.pac != nil {
+		proxyURL, err := e.pac.FindProxy(req.URL.String(), req.URL.Hostname())
+		return e.withUser(proxyURL), err
+	}
+	return e.proxyURL, nil
+}
+
+// ProxyForAddress returns the proxy for a TCP connection to address, or nil to connect directly.
+// A PAC file is asked for the URL tcp://address/.
+func (e *ProxySelector) ProxyForAddress(address string) (*url.URL, error) {
+	scheme := "tcp"
+	if e != nil && e.pac == nil && e.proxyURL == nil {
+		// the environment only configures proxies for http and https
+		scheme = "https"
+	}
+	req, err := http.NewRequest(http.MethodConnect, scheme+"://"+address+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	return e.ProxyForRequest(req)
+}
+
+// ProxyPath describes the path through proxyURL for results, e.g. "DIRECT" or "socks5://proxy:1080", without credentials
+func ProxyPath(proxyURL *url.URL) string {
+	if proxyURL == nil {
+		return "DIRECT"
+	}
+	return proxyURL.Scheme + "://" + proxyURL.Host
+}
+
+// DialThroughProxy opens a TCP connection to address through proxyURL, using HTTP CONNECT or SOCKS5, or directly if proxyURL is nil
+func DialThroughProxy(proxyURL *url.URL, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if proxyURL == nil {
+		return dialer.Dial("tcp", address)
+	}
+	switch proxyURL.Scheme {
+	case "socks5":
+		socksDialer, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socksDialer.Dial("tcp", address)
+	case "http", "https":
+		conn, err := dialer.Dial("tcp", proxyURL.Host)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL.Scheme == "https" {
+			tlsConfig := CreateTLSConfig()
+			tlsConfig.ServerName = proxyURL.Hostname()
+			conn = tls.Client(conn, tlsConfig)
+		}
+		conn.SetDeadline(time.Now().Add(timeout))
+		if err := httpConnect(conn, proxyURL, address); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return conn, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// httpConnect asks the HTTP proxy at the other end of conn to open a tunnel to address
+func httpConnect(conn net.Conn, proxyURL *url.URL, address string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	// nothing is sent through the tunnel until the proxy has replied, so the reader cannot buffer any of it
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy CONNECT to %s failed: %s", address, strings.TrimSpace(resp.Status))
+	}
+	return nil
+}
+
+// proxyPaths counts the requests made through each proxy path by one client
+type proxyPaths struct {
+	mutex sync.Mutex
+	paths map[string]uint
+}
+
+func (e *proxyPaths) add(path string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.paths[path]++
+}
+
+// proxyTransport records the proxy path of the requests made by a client created by CreateHTTPClient
+type proxyTransport struct {
+	http.RoundTripper
+	paths *proxyPaths
+}
+
+// HTTPClientProxyPaths returns the number of requests made through each proxy path by a client created by CreateHTTPClient,
+// e.g. {"DIRECT": 10}, or nil for HTTP/3, which is never proxied
+func HTTPClientProxyPaths(client *http.Client) map[string]uint {
+	transport, ok := client.Transport.(*proxyTransport)
+	if !ok {
+		return nil
+	}
+	transport.paths.mutex.Lock()
+	defer transport.paths.mutex.Unlock()
+	paths := make(map[string]uint, len(transport.paths.paths))
+	for path, count := range transport.paths.paths {
+		paths[path] = count
+	}
+	return paths
+}
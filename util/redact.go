@@ -0,0 +1,57 @@
+package util
+
+import (
+	"net/url"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/types"
+)
+
+// redacted replaces secrets in the config as it is printed and saved
+const redacted = "xxxxx"
+
+// RedactConfig returns a copy of config without the proxy passwords, the passwords in URLs and the exporter tokens
+// and headers, to print or save it
+func RedactConfig(config types.Configuration) types.Configuration {
+	config.Client.Proxy = redactProxy(config.Client.Proxy)
+	for _, path := range []*types.ComparisonPath{&config.Client.Comparison.A, &config.Client.Comparison.B} {
+		if path.Proxy != nil {
+			proxy := redactProxy(*path.Proxy)
+			path.Proxy = &proxy
+		}
+	}
+	config.Client.Metrics.PushURL = RedactURL(config.Client.Metrics.PushURL)
+	config.Client.Exporters.InfluxDB.URL = RedactURL(config.Client.Exporters.InfluxDB.URL)
+	config.Client.Exporters.InfluxDB.Token = redactSecret(config.Client.Exporters.InfluxDB.Token)
+	config.Client.Exporters.OTLP.Endpoint = RedactURL(config.Client.Exporters.OTLP.Endpoint)
+	if headers := config.Client.Exporters.OTLP.Headers; headers != nil {
+		config.Client.Exporters.OTLP.Headers = make(map[string]string, len(headers))
+		for name, value := range headers {
+			config.Client.Exporters.OTLP.Headers[name] = redactSecret(value)
+		}
+	}
+	return config
+}
+
+// RedactURL returns rawURL with the password of its user info replaced, and rawURL unchanged if it has none
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+func redactProxy(proxy types.ProxyConfig) types.ProxyConfig {
+	proxy.URL = RedactURL(proxy.URL)
+	proxy.PAC = RedactURL(proxy.PAC)
+	proxy.Password = redactSecret(proxy.Password)
+	return proxy
+}
+
+// redactSecret returns secret replaced, or empty if it is empty so that an unset secret still shows as unset
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
@@ -0,0 +1,241 @@
+package util
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+// Percentile returns the p-th percentile (0-100) of an ascending slice using the nearest-rank method
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100.0 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// ComputeLatencyStats summarises RTTs given in the order they were measured
+func ComputeLatencyStats(rtts []time.Duration) model.LatencyStats {
+	stats := model.LatencyStats{CountSamples: uint(len(rtts))}
+	if len(rtts) == 0 {
+		return stats
+	}
+
+	sorted := make([]time.Duration, len(rtts))
+	copy(sorted, rtts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	sum := 0.0
+	for _, rtt := range rtts {
+		sum += float64(rtt)
+	}
+	mean := sum / float64(len(rtts))
+	variance := 0.0
+	for _, rtt := range rtts {
+		variance += (float64(rtt) - mean) * (float64(rtt) - mean)
+	}
+	variance /= float64(len(rtts))
+
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	stats.Mean = time.Duration(mean)
+	stats.StdDev = time.Duration(math.Sqrt(variance))
+	stats.P50 = Percentile(sorted, 50)
+	stats.P90 = Percentile(sorted, 90)
+	stats.P95 = Percentile(sorted, 95)
+	stats.P99 = Percentile(sorted, 99)
+
+	var differences []time.Duration
+	for i := 1; i < len(rtts); i++ {
+		differences = append(differences, rtts[i]-rtts[i-1])
+	}
+	setVariation(&stats, differences)
+
+	stats.Buckets = make([]uint, len(model.LatencyBucketBounds))
+	for i, bound := range model.LatencyBucketBounds {
+		stats.Buckets[i] = uint(sort.Search(len(sorted), func(j int) bool { return sorted[j] > bound }))
+	}
+
+	return stats
+}
+
+// ComputePingStats summarises ping samples, counting lost samples but excluding them from the RTT distribution
+func ComputePingStats(samples []model.PingSample) model.LatencyStats {
+	var rtts []time.Duration
+	countLost := uint(0)
+	for _, sample := range samples {
+		if sample.Lost {
+			countLost++
+			continue
+		}
+		rtts = append(rtts, sample.RTT)
+	}
+	stats := ComputeLatencyStats(rtts)
+	stats.CountSamples = uint(len(samples))
+	stats.CountLost = countLost
+	// a lost sample breaks the sequence, so only consecutive received samples are compared
+	var differences []time.Duration
+	for i := 1; i < len(samples); i++ {
+		if !samples[i-1].Lost && !samples[i].Lost {
+			differences = append(differences, samples[i].RTT-samples[i-1].RTT)
+		}
+	}
+	setVariation(&stats, differences)
+	return stats
+}
+
+// setVariation sets the jitter and IPDV of stats from the differences between consecutive RTTs
+func setVariation(stats *model.LatencyStats, differences []time.Duration) {
+	// RFC 3550 section 6.4.1: J(i) = J(i-1) + (|D(i-1,i)| - J(i-1))/16
+	// For RTTs the difference in transit time D is simply the difference between consecutive RTTs.
+	jitter := 0.0
+	sumIPDV := 0.0
+	maxIPDV := 0.0
+	for _, difference := range differences {
+		d := math.Abs(float64(difference))
+		jitter += (d - jitter) / 16.0
+		sumIPDV += d
+		maxIPDV = math.Max(maxIPDV, d)
+	}
+	stats.Jitter = time.Duration(jitter)
+	stats.IPDV_Mean = 0
+	if len(differences) > 0 {
+		stats.IPDV_Mean = time.Duration(sumIPDV / float64(len(differences)))
+	}
+	stats.IPDV_Max = time.Duration(maxIPDV)
+}
+
+// MeanAndStdDev returns the mean and the sample standard deviation of values, which is 0 for fewer than 2 values
+func MeanAndStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// WelchTTest returns the two-tailed p-value of Welch's t-test that a and b have the same mean. It is not defined,
+// and ok is false, unless both have at least 2 values.
+func WelchTTest(a []float64, b []float64) (p float64, ok bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	meanA, stdDevA := MeanAndStdDev(a)
+	meanB, stdDevB := MeanAndStdDev(b)
+	varA := stdDevA * stdDevA / float64(len(a))
+	varB := stdDevB * stdDevB / float64(len(b))
+	if varA+varB == 0 {
+		// no spread at all, so any difference is certain
+		if meanA == meanB {
+			return 1, true
+		}
+		return 0, true
+	}
+	t := (meanA - meanB) / math.Sqrt(varA+varB)
+	// Welch–Satterthwaite degrees of freedom
+	df := (varA + varB) * (varA + varB) / (varA*varA/float64(len(a)-1) + varB*varB/float64(len(b)-1))
+	return StudentTTwoTailed(t, df), true
+}
+
+// StudentTTwoTailed returns the probability that Student's t-distribution with df degrees of freedom is at least |t| from 0
+func StudentTTwoTailed(t float64, df float64) float64 {
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// StudentTQuantile returns the value that Student's t-distribution with df degrees of freedom exceeds with probability
+// (1-confidence)/2, e.g. 2.262 for a 95% confidence interval with df 9
+func StudentTQuantile(confidence float64, df float64) float64 {
+	low, high := 0.0, 1000.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if StudentTTwoTailed(mid, df) > 1-confidence {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// regularizedIncompleteBeta returns I_x(a, b), evaluated by its continued fraction
+func regularizedIncompleteBeta(a float64, b float64, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	lgammaAB, _ := math.Lgamma(a + b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+	// the continued fraction converges quickly only below the mean of the distribution
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+	}
+	return front * betaContinuedFraction(a, b, x) / a
+}
+
+// betaContinuedFraction evaluates the continued fraction of the incomplete beta function with the modified Lentz method
+func betaContinuedFraction(a float64, b float64, x float64) float64 {
+	const epsilon = 1e-14
+	const tiny = 1e-300
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	result := d
+	for m := 1.0; m <= 300; m++ {
+		// even step
+		numerator := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		result *= d * c
+		// odd step
+		numerator = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		result *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return result
+}
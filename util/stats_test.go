@@ -0,0 +1,116 @@
+package util
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jrcamenzuli/network-performance-tester-client/model"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{{0, 1}, {10, 1}, {11, 2}, {50, 5}, {90, 9}, {99, 10}, {100, 10}} {
+		if got := Percentile(sorted, c.p); got != c.want {
+			t.Errorf("Percentile(%v) = %v, want %v", c.p, got, c.want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile of no values = %v, want 0", got)
+	}
+}
+
+func TestComputeLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	stats := ComputeLatencyStats([]time.Duration{10 * ms, 20 * ms, 10 * ms, 40 * ms})
+	if stats.CountSamples != 4 || stats.Min != 10*ms || stats.Max != 40*ms || stats.Mean != 20*ms {
+		t.Errorf("count, min, max, mean = %d, %v, %v, %v, want 4, 10ms, 40ms, 20ms", stats.CountSamples, stats.Min, stats.Max, stats.Mean)
+	}
+	// population standard deviation of 10, 20, 10 and 40 is sqrt(150)
+	if stats.StdDev < 12247*time.Microsecond || stats.StdDev > 12248*time.Microsecond {
+		t.Errorf("stddev = %v, want 12.247ms", stats.StdDev)
+	}
+	if stats.P50 != 10*ms || stats.P99 != 40*ms {
+		t.Errorf("p50, p99 = %v, %v, want 10ms, 40ms", stats.P50, stats.P99)
+	}
+	// differences 10, 10 and 30
+	if stats.IPDV_Max != 30*ms || stats.IPDV_Mean < 16666*time.Microsecond || stats.IPDV_Mean > 16667*time.Microsecond {
+		t.Errorf("IPDV max, mean = %v, %v, want 30ms, 16.667ms", stats.IPDV_Max, stats.IPDV_Mean)
+	}
+	// J = 10/16, then J += (10-J)/16, then J += (30-J)/16
+	jitter := 10.0 / 16
+	jitter += (10 - jitter) / 16
+	jitter += (30 - jitter) / 16
+	if want := time.Duration(jitter * float64(ms)); stats.Jitter != want {
+		t.Errorf("jitter = %v, want %v", stats.Jitter, want)
+	}
+	if len(stats.Buckets) != len(model.LatencyBucketBounds) {
+		t.Errorf("%d buckets, want %d", len(stats.Buckets), len(model.LatencyBucketBounds))
+	}
+
+	if empty := ComputeLatencyStats(nil); empty.CountSamples != 0 || empty.Max != 0 {
+		t.Errorf("stats of no RTTs = %+v", empty)
+	}
+}
+
+func TestComputePingStatsSkipsLostSamples(t *testing.T) {
+	ms := time.Millisecond
+	stats := ComputePingStats([]model.PingSample{
+		{Sequence: 0, RTT: 10 * ms},
+		{Sequence: 1, RTT: 12 * ms},
+		{Sequence: 2, Lost: true},
+		{Sequence: 3, RTT: 50 * ms},
+		{Sequence: 4, RTT: 54 * ms},
+	})
+	if stats.CountSamples != 5 || stats.CountLost != 1 {
+		t.Errorf("count, lost = %d, %d, want 5, 1", stats.CountSamples, stats.CountLost)
+	}
+	// only 12-10 and 54-50 are consecutive, not 50-12 across the lost sample
+	if stats.IPDV_Max != 4*ms || stats.IPDV_Mean != 3*ms {
+		t.Errorf("IPDV max, mean = %v, %v, want 4ms, 3ms", stats.IPDV_Max, stats.IPDV_Mean)
+	}
+}
+
+func TestStudentTQuantile(t *testing.T) {
+	// from tables of Student's t-distribution
+	for _, c := range []struct {
+		confidence float64
+		df         float64
+		want       float64
+	}{{0.95, 1, 12.706}, {0.95, 9, 2.262}, {0.95, 30, 2.042}, {0.99, 10, 3.169}, {0.90, 5, 2.015}} {
+		if got := StudentTQuantile(c.confidence, c.df); math.Abs(got-c.want) > 0.001 {
+			t.Errorf("StudentTQuantile(%v, %v) = %.4f, want %.3f", c.confidence, c.df, got, c.want)
+		}
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	a := []float64{1, 2, 3, 4, 5}
+	// the same spread shifted by 1 gives t = 1 with df 8, and shifted by 2.306, the 97.5% quantile of df 8, gives p = 0.05
+	for _, c := range []struct {
+		shift float64
+		want  float64
+	}{{0, 1}, {1, 0.3466}, {2.306, 0.05}} {
+		b := make([]float64, len(a))
+		for i, value := range a {
+			b[i] = value + c.shift
+		}
+		p, ok := WelchTTest(a, b)
+		if !ok || math.Abs(p-c.want) > 0.0005 {
+			t.Errorf("WelchTTest shifted by %v = %.4f, %v, want %.4f", c.shift, p, ok, c.want)
+		}
+	}
+	// unequal sizes and variances: t = -9.02 with df 7.9
+	if p, ok := WelchTTest([]float64{10, 12, 11, 13}, []float64{20, 21, 19, 25, 22, 24}); !ok || p > 0.001 {
+		t.Errorf("WelchTTest of clearly different means = %.4f, %v, want < 0.001", p, ok)
+	}
+	if p, ok := WelchTTest([]float64{3, 3}, []float64{4, 4}); !ok || p != 0 {
+		t.Errorf("WelchTTest without spread = %v, %v, want 0, true", p, ok)
+	}
+	if _, ok := WelchTTest([]float64{1}, []float64{1, 2}); ok {
+		t.Errorf("WelchTTest of a single value is defined")
+	}
+}
@@ -3,8 +3,9 @@ package util
 import (
 	"encoding/json"
 	"flag"
-	"fmt"
 	"io/ioutil"
+	"log/slog"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -14,28 +15,18 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-const (
-	InfoColor    = "\033[1;34m%s\033[0m"
-	NoticeColor  = "\033[1;36m%s\033[0m"
-	WarningColor = "\033[1;33m%s\033[0m"
-	ErrorColor   = "\033[1;31m%s\033[0m"
-	DebugColor   = "\033[0;36m%s\033[0m"
-)
-
 func Args() types.ProgramArgs {
 	configPtr := flag.String("config", "config.yml", "")
 	// if len(*postfixPtr) > 0 {
 	// 	(*postfixPtr) = "-" + *postfixPtr
 	// }
 	flag.Parse()
-	fmt.Println()
-	fmt.Println("config:", *configPtr)
-	fmt.Println()
+	slog.Info("Reading the configuration", "file", *configPtr)
 
 	if _, err := os.Stat("test-results"); os.IsNotExist(err) {
 		err := os.Mkdir("test-results", 0700)
 		if err != nil {
-			fmt.Println("Could not create test-results directory")
+			slog.Error("Could not create the test-results directory", "error", err)
 		}
 	}
 
@@ -62,7 +53,7 @@ func getCPUSample() (idle, total uint64) {
 			for i := 1; i < numFields; i++ {
 				val, err := strconv.ParseUint(fields[i], 10, 64)
 				if err != nil {
-					fmt.Println("Error: ", i, fields[i], err)
+					slog.Error("Could not parse /proc/stat", "field", i, "value", fields[i], "error", err)
 				}
 				total += val // tally up all the numbers to get total ticks
 				if i == 4 {  // idle is the 5th field in the cpu line
@@ -76,7 +67,7 @@ func getCPUSample() (idle, total uint64) {
 }
 
 func processError(err error) {
-	fmt.Println(err)
+	slog.Error("Could not read the configuration", "error", err)
 	os.Exit(2)
 }
 
@@ -108,3 +99,21 @@ func PrettifyStruct(o interface{}) string {
 	}
 	return string(out)
 }
+
+// Sparkline draws values as a line of block characters scaled from their minimum to their maximum
+func Sparkline(values []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		min, max = math.Min(min, value), math.Max(max, value)
+	}
+	var line strings.Builder
+	for _, value := range values {
+		i := 0
+		if max > min {
+			i = int((value - min) / (max - min) * float64(len(blocks)-1))
+		}
+		line.WriteRune(blocks[i])
+	}
+	return line.String()
+}